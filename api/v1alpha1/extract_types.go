@@ -17,7 +17,12 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/operator-framework/operator-lib/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,21 +35,632 @@ const (
 	// ReconciledReasonError indicates an error was encountered while
 	// reconciling the CR
 	ReconciledReasonError status.ConditionReason = "ReconcileError"
+	// ConditionRepoNotAllowed indicates whether the remote of the sink is
+	// missing from the repository allowlist of the manager
+	ConditionRepoNotAllowed status.ConditionType = "RepoNotAllowed"
+	// RepoNotAllowedReason is set while the remote is not allowed
+	RepoNotAllowedReason status.ConditionReason = "RepoNotAllowed"
+	// RepoAllowedReason is set once the remote is allowed
+	RepoAllowedReason status.ConditionReason = "RepoAllowed"
+	// ConditionQueued indicates whether the extraction waits for another
+	// extraction pushing to the same repository and branch
+	ConditionQueued status.ConditionType = "Queued"
+	// QueuedReasonWaiting is set while the extraction waits
+	QueuedReasonWaiting status.ConditionReason = "WaitingForTarget"
+	// QueuedReasonStarted is set once the extraction started
+	QueuedReasonStarted status.ConditionReason = "Started"
+)
+
+// RunAnnotation starts another run of a completed Extract or Import whenever
+// its value changes, such as to the time of the request
+const RunAnnotation = "primer.gitops.io/run"
+
+// ExtractTrigger selects when extractions run
+// +kubebuilder:validation:Enum=Once;OnChange
+type ExtractTrigger string
+
+const (
+	// TriggerOnce runs a single extraction
+	TriggerOnce ExtractTrigger = "Once"
+	// TriggerOnChange runs an extraction whenever watched objects change
+	TriggerOnChange ExtractTrigger = "OnChange"
 )
 
 type ExtractSpec struct {
-	Branch string `json:"branch"`
-	Repo   string `json:"repo"`
-	Email  string `json:"email"`
-	Secret string `json:"secret"`
+	// Branch of the git sink
+	// +optional
+	Branch string `json:"branch,omitempty"`
+	// Repo is the URL of the git sink
+	// +optional
+	Repo string `json:"repo,omitempty"`
+	// CreateBranch creates the branch of the git sink if it does not exist
+	// +optional
+	CreateBranch bool `json:"createBranch,omitempty"`
+	// BaseBranch is the branch created branches start from, without it they
+	// are created as orphans
+	// +optional
+	BaseBranch string `json:"baseBranch,omitempty"`
+	// Email of the commits of the git sink
+	// +optional
+	Email string `json:"email,omitempty"`
+	// Tagging tags the commits pushed by the git sink
+	// +optional
+	Tagging *TaggingSpec `json:"tagging,omitempty"`
+	// Secret holding the SSH key of the git sink
+	// +optional
+	Secret string `json:"secret,omitempty"`
+	// Trigger selects when extractions run, defaults to Once
+	// +optional
+	Trigger ExtractTrigger `json:"trigger,omitempty"`
+	// OnChange configures extractions run by the OnChange trigger
+	// +optional
+	OnChange *OnChangeSpec `json:"onChange,omitempty"`
+	// Path is the directory of the repository the extraction writes to and
+	// prunes, defaults to the root or clusters/<cluster name> for other
+	// clusters. It is a template with the variables {{.Namespace}},
+	// {{.Name}} and {{.ClusterName}}; paths escaping the repository are
+	// refused.
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Output configures how the extracted objects are laid out in the repository
+	// +optional
+	Output *ExtractOutput `json:"output,omitempty"`
+	// Secrets configures how Secrets are stored in the repository
+	// +optional
+	Secrets *SecretsSpec `json:"secrets,omitempty"`
+	// Sink selects where the extracted files are stored, defaults to git
+	// +optional
+	Sink *ExtractSink `json:"sink,omitempty"`
+	// Source selects the cluster the namespace is extracted from, defaults to
+	// the namespace of the Extract
+	// +optional
+	Source *ExtractSource `json:"source,omitempty"`
+	// RetainLogs keeps the log of the last extraction in the ConfigMap
+	// primer-extract-<name>-logs once its pod is cleaned up
+	// +optional
+	RetainLogs bool `json:"retainLogs,omitempty"`
+}
+
+// TaggingSpec configures the annotated tags the git sink creates after every
+// push, as point-in-time snapshots of the extraction
+type TaggingSpec struct {
+	// Name is a template of the tag name with the variables {{.Date}}
+	// (2006-01-02), {{.Timestamp}} (20060102T150405Z), {{.Run}} (the number
+	// of the extraction), {{.Namespace}} and {{.Name}}. Names already taken
+	// get the suffix -2, -3 and so on.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Retention is the number of tags matching the name template for this
+	// Extract kept, older ones are deleted. All tags are kept when unset.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Retention int32 `json:"retention,omitempty"`
+}
+
+// ExtractSource configures extractions of namespaces of other clusters
+type ExtractSource struct {
+	// KubeconfigSecretRef selects the key of a Secret in the namespace of the
+	// Extract holding the kubeconfig of the cluster
+	KubeconfigSecretRef corev1.SecretKeySelector `json:"kubeconfigSecretRef"`
+	// Namespace of the cluster to extract, defaults to the namespace of the
+	// Extract
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// ClusterName names the cluster in the output layout, defaults to the name
+	// of the kubeconfig Secret
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	ClusterName string `json:"clusterName,omitempty"`
+}
+
+// SourceNamespace returns the namespace extracted by the spec, which is
+// namespace unless the source selects another one
+func (s *ExtractSpec) SourceNamespace(namespace string) string {
+	if s.Source != nil && s.Source.Namespace != "" {
+		return s.Source.Namespace
+	}
+	return namespace
+}
+
+// ClusterName returns the name of the extracted cluster, or "" for the
+// cluster of the Extract
+func (s *ExtractSpec) ClusterName() string {
+	if s.Source == nil {
+		return ""
+	}
+	if s.Source.ClusterName != "" {
+		return s.Source.ClusterName
+	}
+	return s.Source.KubeconfigSecretRef.Name
+}
+
+// ClusterDirectory returns the directory holding the output of an extraction
+// of another cluster, clusters/<name>, or "" for the cluster of the Extract
+func (s *ExtractSpec) ClusterDirectory() string {
+	if s.Source == nil {
+		return ""
+	}
+	return "clusters/" + s.ClusterName()
+}
+
+// SinkType selects where the extracted files are stored
+// +kubebuilder:validation:Enum=git;s3;oci;pvc;configmap
+type SinkType string
+
+const (
+	// SinkGit commits the files to the branch of the repo
+	SinkGit SinkType = "git"
+	// SinkS3 uploads the files as tarball to S3 compatible object storage
+	SinkS3 SinkType = "s3"
+	// SinkOCI pushes the files as OCI artifact to a registry
+	SinkOCI SinkType = "oci"
+	// SinkPVC writes the files to a PersistentVolumeClaim owned by the Extract
+	SinkPVC SinkType = "pvc"
+	// SinkConfigMap stores the files as gzipped tarball in a ConfigMap owned
+	// by the Extract
+	SinkConfigMap SinkType = "configmap"
+)
+
+// Remote returns where the sink of the spec pushes to: the git repository,
+// the bucket below the S3 endpoint or the OCI repository. It is empty for
+// the in-cluster sinks.
+func (s *ExtractSpec) Remote() string {
+	if s.Sink == nil || s.Sink.Type == "" || s.Sink.Type == SinkGit {
+		return s.Repo
+	}
+	switch {
+	case s.Sink.Type == SinkS3 && s.Sink.S3 != nil:
+		return strings.TrimSuffix(s.Sink.S3.Endpoint, "/") + "/" + s.Sink.S3.Bucket
+	case s.Sink.Type == SinkOCI && s.Sink.OCI != nil:
+		return s.Sink.OCI.Repository
+	}
+	return ""
+}
+
+// ValidateSink checks that the spec configures its sink: the git sink needs
+// the repo and branch, the s3 and oci sinks their settings
+func (s *ExtractSpec) ValidateSink() error {
+	if s.Sink == nil || s.Sink.Type == "" || s.Sink.Type == SinkGit {
+		if s.Repo == "" || s.Branch == "" {
+			return fmt.Errorf("the git sink requires repo and branch")
+		}
+		return nil
+	}
+	switch {
+	case s.Sink.Type == SinkS3 && s.Sink.S3 == nil:
+		return fmt.Errorf("the s3 sink requires sink.s3")
+	case s.Sink.Type == SinkOCI && s.Sink.OCI == nil:
+		return fmt.Errorf("the oci sink requires sink.oci")
+	}
+	return nil
+}
+
+// OutputName is the name of the PersistentVolumeClaim or ConfigMap of the
+// in-cluster sinks of an Extract
+func OutputName(extract string) string {
+	return "primer-extract-" + extract + "-output"
+}
+
+// LogsName is the name of the ConfigMap holding the retained log of the last
+// extraction of an Extract
+func LogsName(extract string) string {
+	return "primer-extract-" + extract + "-logs"
+}
+
+// LogsKey is the key of the log in the ConfigMap named by LogsName
+const LogsKey = "extract.log"
+
+// ExtractSink configures where the extracted files are stored
+type ExtractSink struct {
+	// Type of the sink, defaults to git
+	// +optional
+	Type SinkType `json:"type,omitempty"`
+	// S3 configures the s3 sink
+	// +optional
+	S3 *S3Sink `json:"s3,omitempty"`
+	// OCI configures the oci sink
+	// +optional
+	OCI *OCISink `json:"oci,omitempty"`
+	// PVC configures the pvc sink
+	// +optional
+	PVC *PVCSink `json:"pvc,omitempty"`
+}
+
+// PVCSink configures the PersistentVolumeClaim created for the pvc sink
+type PVCSink struct {
+	// StorageClassName of the claim, defaults to the default storage class
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// Size of the claim, defaults to 1Gi
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+}
+
+// OCISink pushes every extraction as OCI artifact tagged with the time of
+// the run and latest
+type OCISink struct {
+	// Repository the artifact is pushed to, such as
+	// registry.example.com/team/manifests
+	Repository string `json:"repository"`
+	// CredentialsSecret names a kubernetes.io/dockerconfigjson Secret
+	// holding the credentials of the registry
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// Insecure talks plain HTTP to the registry
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// S3Sink uploads every extraction as timestamped tarball
+type S3Sink struct {
+	// Endpoint is the URL of the S3 compatible service, objects are
+	// addressed in path style
+	Endpoint string `json:"endpoint"`
+	// Region defaults to us-east-1
+	// +optional
+	Region string `json:"region,omitempty"`
+	Bucket string `json:"bucket"`
+	// Prefix of the object keys
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// CredentialsSecret names the Secret holding AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY
+	CredentialsSecret string `json:"credentialsSecret"`
+	// Retention is the number of tarballs kept per namespace, older ones
+	// are deleted. All tarballs are kept when unset.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Retention int32 `json:"retention,omitempty"`
+}
+
+// SecretPolicy selects how Secrets are stored
+// +kubebuilder:validation:Enum=Exclude;Redact;SOPS;SealedSecrets;ExternalSecret
+type SecretPolicy string
+
+const (
+	// SecretPolicyExclude leaves Secrets out
+	SecretPolicyExclude SecretPolicy = "Exclude"
+	// SecretPolicyRedact keeps the keys of Secrets and blanks their values
+	SecretPolicyRedact SecretPolicy = "Redact"
+	// SecretPolicySOPS encrypts the values of Secrets with SOPS and age
+	SecretPolicySOPS SecretPolicy = "SOPS"
+	// SecretPolicySealedSecrets replaces Secrets by SealedSecrets
+	SecretPolicySealedSecrets SecretPolicy = "SealedSecrets"
+	// SecretPolicyExternalSecret replaces Secrets by ExternalSecrets
+	// referencing an external secret store
+	SecretPolicyExternalSecret SecretPolicy = "ExternalSecret"
+)
+
+// SecretsSpec configures how Secrets are stored. Plaintext values are never
+// written to the repository.
+type SecretsSpec struct {
+	// Policy defaults to Exclude
+	// +optional
+	Policy SecretPolicy `json:"policy,omitempty"`
+	// SOPS configures the SOPS policy
+	// +optional
+	SOPS *SOPSSpec `json:"sops,omitempty"`
+	// SealedSecrets configures the SealedSecrets policy
+	// +optional
+	SealedSecrets *SealedSecretsSpec `json:"sealedSecrets,omitempty"`
+	// ExternalSecret configures the ExternalSecret policy
+	// +optional
+	ExternalSecret *ExternalSecretSpec `json:"externalSecret,omitempty"`
+}
+
+// SOPSSpec configures the encryption of Secrets with SOPS
+type SOPSSpec struct {
+	// AgeRecipients selects the ConfigMap key holding the age public keys
+	// Secrets are encrypted for, one per line
+	AgeRecipients corev1.ConfigMapKeySelector `json:"ageRecipients"`
+}
+
+// ExternalSecretSpec configures the ExternalSecrets replacing Secrets. The
+// templates are Go templates with the fields .Namespace, .Name and .Key, the
+// name and key of the Secret.
+type ExternalSecretSpec struct {
+	// SecretStoreRef is the store the values are read from
+	SecretStoreRef ExternalSecretStoreRef `json:"secretStoreRef"`
+	// KeyTemplate renders the remote key of a value, defaults to
+	// {{ .Namespace }}/{{ .Name }}
+	// +optional
+	KeyTemplate string `json:"keyTemplate,omitempty"`
+	// PropertyTemplate renders the property of the remote key holding a
+	// value, defaults to {{ .Key }}. The property is omitted when empty.
+	// +optional
+	PropertyTemplate *string `json:"propertyTemplate,omitempty"`
+	// RefreshInterval defaults to 1h
+	// +optional
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// ExternalSecretStoreRef references a SecretStore or ClusterSecretStore
+type ExternalSecretStoreRef struct {
+	Name string `json:"name"`
+	// Kind defaults to SecretStore
+	// +kubebuilder:validation:Enum=SecretStore;ClusterSecretStore
+	// +optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// SealedSecretsScope is the scope a SealedSecret can be decrypted in
+// +kubebuilder:validation:Enum=strict;namespace-wide;cluster-wide
+type SealedSecretsScope string
+
+const (
+	// SealedSecretsStrict binds a SealedSecret to its name and namespace
+	SealedSecretsStrict SealedSecretsScope = "strict"
+	// SealedSecretsNamespaceWide binds a SealedSecret to its namespace
+	SealedSecretsNamespaceWide SealedSecretsScope = "namespace-wide"
+	// SealedSecretsClusterWide allows a SealedSecret to be used anywhere
+	SealedSecretsClusterWide SealedSecretsScope = "cluster-wide"
+)
+
+// SealedSecretsSpec configures the sealing of Secrets
+type SealedSecretsSpec struct {
+	// Certificate selects the ConfigMap key holding the PEM encoded
+	// certificate of the sealed secrets controller
+	Certificate corev1.ConfigMapKeySelector `json:"certificate"`
+	// Scope defaults to strict
+	// +optional
+	Scope SealedSecretsScope `json:"scope,omitempty"`
+}
+
+// OutputFormat selects the layout of the extracted objects
+// +kubebuilder:validation:Enum=flat;kustomize;helm
+type OutputFormat string
+
+const (
+	// OutputFlat writes one file per object below resources/<namespace>
+	OutputFlat OutputFormat = "flat"
+	// OutputKustomize writes a kustomize base below base/
+	OutputKustomize OutputFormat = "kustomize"
+	// OutputHelm writes a Helm chart below chart/
+	OutputHelm OutputFormat = "helm"
+)
+
+// ExtractOutput configures how the extracted objects are laid out
+type ExtractOutput struct {
+	// Format of the output, defaults to flat
+	// +optional
+	Format OutputFormat `json:"format,omitempty"`
+	// Helm configures the helm format
+	// +optional
+	Helm *HelmOutput `json:"helm,omitempty"`
+	// ArgoCD writes Argo CD manifests deploying the extracted objects
+	// +optional
+	ArgoCD *ArgoCDOutput `json:"argocd,omitempty"`
+	// Flux writes Flux objects deploying the extracted objects
+	// +optional
+	Flux *FluxOutput `json:"flux,omitempty"`
+}
+
+// ArgoCDOutput configures the Argo CD manifests written next to the
+// extracted objects. They point at the same repo, branch and path.
+type ArgoCDOutput struct {
+	// Enabled writes the Argo CD manifests
+	Enabled bool `json:"enabled"`
+	// Kind is Application, or ApplicationSet to write one git generator entry
+	// per namespace next to a shared ApplicationSet. Defaults to Application.
+	// +kubebuilder:validation:Enum=Application;ApplicationSet
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Name of the Application, defaults to the namespace. Name of the
+	// ApplicationSet, defaults to gitops-primer.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Namespace the Argo CD objects are created in, defaults to argocd
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Directory of the repository the manifests are written to, defaults to argocd
+	// +optional
+	Directory string `json:"directory,omitempty"`
+	// Project of the Application, defaults to default
+	// +optional
+	Project string `json:"project,omitempty"`
+	// Destination of the Application, defaults to the cluster Argo CD runs
+	// in and the extracted namespace
+	// +optional
+	Destination *ArgoCDDestination `json:"destination,omitempty"`
+	// SyncPolicy of the Application
+	// +optional
+	SyncPolicy *ArgoCDSyncPolicy `json:"syncPolicy,omitempty"`
+}
+
+// FluxOutput configures the Flux GitRepository and Kustomization written
+// next to the extracted objects. They point at the same repo, branch and path.
+type FluxOutput struct {
+	// Enabled writes the Flux objects
+	Enabled bool `json:"enabled"`
+	// Name of the GitRepository and Kustomization, defaults to the namespace
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Namespace the Flux objects are created in, defaults to flux-system
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Directory of the repository the objects are written to, defaults to flux
+	// +optional
+	Directory string `json:"directory,omitempty"`
+	// SecretRef is the name of the secret Flux uses to clone the repository
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+	// Interval of the reconciliation, defaults to 10m
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Prune deletes objects removed from the repository
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+	// TargetNamespace defaults to the extracted namespace
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+}
+
+// ArgoCDDestination is the cluster and namespace an Application deploys to
+type ArgoCDDestination struct {
+	// Server is the API server URL, defaults to https://kubernetes.default.svc
+	// +optional
+	Server string `json:"server,omitempty"`
+	// Name of the cluster, used instead of Server when set
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Namespace defaults to the extracted namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ArgoCDSyncPolicy is the sync policy of an Application
+type ArgoCDSyncPolicy struct {
+	// Automated enables automated sync
+	// +optional
+	Automated *ArgoCDAutomatedSync `json:"automated,omitempty"`
+	// SyncOptions such as CreateNamespace=true
+	// +optional
+	SyncOptions []string `json:"syncOptions,omitempty"`
+}
+
+// ArgoCDAutomatedSync configures automated sync
+type ArgoCDAutomatedSync struct {
+	// Prune deletes objects removed from the repository
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+	// SelfHeal reverts changes made in the cluster
+	// +optional
+	SelfHeal bool `json:"selfHeal,omitempty"`
+}
+
+// HelmOutput configures the chart written by the helm format
+type HelmOutput struct {
+	// ChartName is the name of the chart, defaults to the namespace
+	// +optional
+	ChartName string `json:"chartName,omitempty"`
+	// Values are the rules moving object fields into values.yaml. Container
+	// images, replica counts and the namespace are parameterized when empty.
+	// +optional
+	Values []HelmValueRule `json:"values,omitempty"`
+}
+
+// HelmValueRule moves the fields selected by a JSONPath expression into
+// values.yaml
+type HelmValueRule struct {
+	// Name is the key in values.yaml the fields are stored under
+	Name string `json:"name"`
+	// Path selects the fields, e.g. {.spec.template.spec.containers[*].image}.
+	// Field names, ['quoted names'], [index] and [*] are supported.
+	Path string `json:"path"`
+	// Kinds limits the rule to objects of these kinds, all kinds when empty
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+	// Shared stores one value for all objects instead of one per object.
+	// Fields holding a different value than the first match are kept as is.
+	// +optional
+	Shared bool `json:"shared,omitempty"`
+}
+
+// OnChangeSpec configures change driven extractions
+type OnChangeSpec struct {
+	// Resources are the kinds watched for changes. A default set of workload,
+	// configuration and RBAC kinds is watched when empty.
+	// +optional
+	Resources []WatchedResource `json:"resources,omitempty"`
+	// Debounce is how long changes are collected before an extraction is
+	// triggered, defaults to 30s
+	// +optional
+	Debounce *metav1.Duration `json:"debounce,omitempty"`
+	// MinInterval is the minimum time between two extractions, defaults to 5m
+	// +optional
+	MinInterval *metav1.Duration `json:"minInterval,omitempty"`
+}
+
+// WatchedResource identifies a kind watched for changes
+type WatchedResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
 }
 
 // ExtractStatus defines the observed state of Extract
 type ExtractStatus struct {
-	Completed  bool              `json:"completed,omitempty"`
+	Completed bool `json:"completed,omitempty"`
+	// LastRunTime is when the last extraction Job was created
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// Digest of the OCI artifact pushed by the last extraction
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// Excluded lists the resources the requester cannot list, as
+	// resource.group. They are left out of the extraction.
+	// +optional
+	Excluded []string `json:"excluded,omitempty"`
+	// Revision is the commit of the git sink holding the last extraction
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// BranchCreated is set once an extraction created the branch of the git
+	// sink
+	// +optional
+	BranchCreated bool `json:"branchCreated,omitempty"`
+	// Objects is the number of objects stored by the last extraction
+	// +optional
+	Objects int32 `json:"objects,omitempty"`
+	// RunRequest is the value of the run annotation when the last extraction
+	// started
+	// +optional
+	RunRequest string `json:"runRequest,omitempty"`
+	// Runs counts the extractions started, it numbers the tags of the git
+	// sink
+	// +optional
+	Runs int32 `json:"runs,omitempty"`
+	// History lists the most recent extractions, newest first
+	// +optional
+	History    []ExtractRun      `json:"history,omitempty"`
 	Conditions status.Conditions `json:"conditions,omitempty"`
 }
 
+// ExtractRunResult is the outcome of an extraction
+type ExtractRunResult string
+
+const (
+	// RunSucceeded is recorded for extractions that stored their files
+	RunSucceeded ExtractRunResult = "Succeeded"
+	// RunFailed is recorded for extractions whose Job failed
+	RunFailed ExtractRunResult = "Failed"
+)
+
+// ExtractRun records a finished extraction
+type ExtractRun struct {
+	// StartTime is when the Job of the extraction was created
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the extraction was found finished
+	CompletionTime metav1.Time `json:"completionTime"`
+	// Result tells whether the extraction succeeded
+	Result ExtractRunResult `json:"result"`
+	// Location is where the files were stored
+	// +optional
+	Location string `json:"location,omitempty"`
+	// Changed is false when the sink already held the same files
+	// +optional
+	Changed bool `json:"changed,omitempty"`
+	// Revision is the commit of the git sink holding the files
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// Objects is the number of stored objects
+	// +optional
+	Objects int32 `json:"objects,omitempty"`
+	// PushRetries counts the pushes of the git sink retried on top of a
+	// branch that moved
+	// +optional
+	PushRetries int32 `json:"pushRetries,omitempty"`
+	// CreatedBranch is set when the extraction created the branch of the
+	// git sink
+	// +optional
+	CreatedBranch bool `json:"createdBranch,omitempty"`
+	// Tag is the tag the git sink created for the extraction
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// Message explains why the extraction failed, or what failed after a
+	// successful push such as tagging
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 
@@ -0,0 +1,77 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/operator-framework/operator-lib/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExtractSetLabel is set on every Extract created by an ExtractSet and holds
+// the name of the owning ExtractSet
+const ExtractSetLabel = "primer.gitops.io/extractset"
+
+// ExtractSetSpec defines the desired state of ExtractSet
+type ExtractSetSpec struct {
+	// NamespaceSelector selects the namespaces that receive an Extract
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+	// Template is the spec of the Extract created in each selected namespace.
+	// The repo, branch and path may reference the namespace as
+	// {{ .Namespace }}.
+	Template ExtractSpec `json:"template"`
+}
+
+// ExtractSetStatus defines the observed state of ExtractSet
+type ExtractSetStatus struct {
+	// Namespaces lists the namespaces currently selected by the ExtractSet
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Extracts is the number of Extracts owned by the ExtractSet
+	Extracts int32 `json:"extracts,omitempty"`
+	// Conflicts lists the selected namespaces holding an Extract of the same
+	// name the ExtractSet does not own. They are left alone.
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Completed is the number of owned Extracts that have completed
+	Completed  int32             `json:"completed,omitempty"`
+	Conditions status.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// ExtractSet is the Schema for the extractsets API
+type ExtractSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ExtractSetSpec   `json:"spec,omitempty"`
+	Status ExtractSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ExtractSetList contains a list of ExtractSet
+type ExtractSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ExtractSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ExtractSet{}, &ExtractSetList{})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/operator-framework/operator-lib/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImportLabel is set on every object applied by an Import and holds the name
+// of the Import. Only objects carrying it are pruned.
+const ImportLabel = "primer.gitops.io/import"
+
+// ImportSpec defines the desired state of Import
+type ImportSpec struct {
+	// Repo is the git repository holding the extraction
+	Repo string `json:"repo"`
+	// Branch to clone, defaults to the default branch of the repository
+	// +optional
+	Branch string `json:"branch,omitempty"`
+	// Revision is the commit or tag to import, defaults to the head of the
+	// branch
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// Path of the directory holding the objects within the repository, such
+	// as resources/<namespace> of the flat format or base of the kustomize
+	// format
+	Path string `json:"path"`
+	// TargetNamespace receives the namespaced objects, defaults to the
+	// namespace of the Import. It is created if it does not exist.
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// Prune deletes the objects applied by the previous run of the Import
+	// that are no longer in the repository
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+	// Secret holds the SSH key the repository is cloned with as id_rsa
+	// +optional
+	Secret string `json:"secret,omitempty"`
+	// ServiceAccountName is the service account in the namespace of the
+	// Import that applies the objects. Without it the objects are applied
+	// with the admin role of the target namespace.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+// ImportResult is the outcome of importing a single object
+type ImportResult string
+
+const (
+	// ImportCreated means the object did not exist before
+	ImportCreated ImportResult = "Created"
+	// ImportConfigured means the object existed and was changed
+	ImportConfigured ImportResult = "Configured"
+	// ImportUnchanged means the object already matched the repository
+	ImportUnchanged ImportResult = "Unchanged"
+	// ImportPruned means the object was deleted since it is no longer in the
+	// repository
+	ImportPruned ImportResult = "Pruned"
+	// ImportFailed means the object could not be applied or pruned
+	ImportFailed ImportResult = "Failed"
+)
+
+// ImportedResource reports what happened to an object of the repository
+type ImportedResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// +optional
+	Namespace string       `json:"namespace,omitempty"`
+	Result    ImportResult `json:"result"`
+	// Message explains failures
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ImportStatus defines the observed state of Import
+type ImportStatus struct {
+	Completed   bool         `json:"completed,omitempty"`
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// ObservedGeneration is the generation of the Import the last run
+	// started with, a completed Import runs again once its spec changed
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// RunRequest is the value of the run annotation when the last run
+	// started
+	// +optional
+	RunRequest string `json:"runRequest,omitempty"`
+	// Revision is the commit that was imported
+	Revision string `json:"revision,omitempty"`
+	// Resources lists the objects of the last run in the order they were
+	// applied
+	Resources  []ImportedResource `json:"resources,omitempty"`
+	Conditions status.Conditions  `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// Import is the Schema for the imports API
+type Import struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImportSpec   `json:"spec,omitempty"`
+	Status ImportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ImportList contains a list of Import
+type ImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Import `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Import{}, &ImportList{})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/cooktheryan/gitops-primer/pkg/allowlist"
+)
+
+//+kubebuilder:webhook:path=/validate-primer-gitops-io-v1alpha1-repo,mutating=false,failurePolicy=fail,sideEffects=None,groups=primer.gitops.io,resources=extracts;extractsets,verbs=create;update,versions=v1alpha1,name=repo.primer.gitops.io,admissionReviewVersions={v1,v1beta1}
+
+// RepoValidator rejects Extracts and ExtractSets without the settings of
+// their sink or pushing to a remote missing from the repository allowlist of
+// the manager
+// +kubebuilder:object:generate=false
+type RepoValidator struct {
+	Reader client.Reader
+	// Config is the manager ConfigMap holding the allowlist
+	Config types.NamespacedName
+}
+
+// Handle checks the sink settings and the remote of the sink against the
+// allowlist
+func (v *RepoValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var spec ExtractSpec
+	switch req.Kind.Kind {
+	case "Extract":
+		extract := &Extract{}
+		if err := json.Unmarshal(req.Object.Raw, extract); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		spec = extract.Spec
+	case "ExtractSet":
+		extractSet := &ExtractSet{}
+		if err := json.Unmarshal(req.Object.Raw, extractSet); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		spec = extractSet.Spec.Template
+	default:
+		return admission.Allowed("")
+	}
+
+	if err := spec.ValidateSink(); err != nil {
+		return admission.Denied(err.Error())
+	}
+	remote := spec.Remote()
+	// Templated remotes are checked once the Extracts exist
+	if remote == "" || strings.Contains(remote, "{{") {
+		return admission.Allowed("")
+	}
+	list, err := allowlist.Load(ctx, v.Reader, v.Config)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if !list.Allows(remote) {
+		return admission.Denied(fmt.Sprintf("%s is not in the repository allowlist", remote))
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("RepoValidator", func() {
+	config := types.NamespacedName{Name: "gitops-primer-config", Namespace: "gitops-primer-system"}
+	var validator *RepoValidator
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: config.Name, Namespace: config.Namespace},
+			Data:       map[string]string{"repoAllowlist": "- github.com/org/*\n- s3.example.com\n- registry.example.com\n"},
+		}
+		validator = &RepoValidator{
+			Reader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build(),
+			Config: config,
+		}
+	})
+
+	review := func(kind string, obj interface{}) admission.Response {
+		raw, err := json.Marshal(obj)
+		Expect(err).NotTo(HaveOccurred())
+		return validator.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: kind},
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}})
+	}
+	extract := func(spec ExtractSpec) *Extract {
+		return &Extract{ObjectMeta: metav1.ObjectMeta{Name: "primer", Namespace: "test"}, Spec: spec}
+	}
+
+	It("allows complete sinks pushing to allowed remotes", func() {
+		Expect(review("Extract", extract(ExtractSpec{Repo: "git@github.com:org/repo.git", Branch: "main"})).Allowed).To(BeTrue())
+		Expect(review("Extract", extract(ExtractSpec{Sink: &ExtractSink{
+			Type: SinkS3,
+			S3:   &S3Sink{Endpoint: "https://s3.example.com", Bucket: "backups"},
+		}})).Allowed).To(BeTrue())
+		Expect(review("Extract", extract(ExtractSpec{Sink: &ExtractSink{Type: SinkPVC}})).Allowed).To(BeTrue())
+	})
+
+	It("refuses git sinks without repo and branch", func() {
+		response := review("Extract", extract(ExtractSpec{Repo: "git@github.com:org/repo.git"}))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(string(response.Result.Reason)).To(ContainSubstring("requires repo and branch"))
+
+		set := &ExtractSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenants"},
+			Spec:       ExtractSetSpec{Template: ExtractSpec{Sink: &ExtractSink{Type: SinkGit}, Branch: "main"}},
+		}
+		Expect(review("ExtractSet", set).Allowed).To(BeFalse())
+	})
+
+	It("refuses s3 and oci sinks without their settings", func() {
+		response := review("Extract", extract(ExtractSpec{Sink: &ExtractSink{Type: SinkS3}}))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(string(response.Result.Reason)).To(ContainSubstring("sink.s3"))
+		response = review("Extract", extract(ExtractSpec{Sink: &ExtractSink{Type: SinkOCI}}))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(string(response.Result.Reason)).To(ContainSubstring("sink.oci"))
+	})
+
+	It("refuses remotes missing from the allowlist", func() {
+		response := review("Extract", extract(ExtractSpec{Repo: "git@gitlab.com:org/repo.git", Branch: "main"}))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(string(response.Result.Reason)).To(ContainSubstring("not in the repository allowlist"))
+	})
+})
@@ -0,0 +1,127 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// RequesterAnnotation holds the name of the user that created an
+	// Extract, ExtractSet or Import. Extractions only read the resources this
+	// user can list, Imports only target other namespaces this user may bind
+	// the admin role in.
+	RequesterAnnotation = "primer.gitops.io/requester"
+	// RequesterGroupsAnnotation holds the groups of the requester as JSON
+	// array
+	RequesterGroupsAnnotation = "primer.gitops.io/requester-groups"
+)
+
+// Requester returns the user and groups recorded on an object by the
+// RequesterRecorder
+func Requester(obj metav1.Object) (string, []string, bool) {
+	user := obj.GetAnnotations()[RequesterAnnotation]
+	if user == "" {
+		return "", nil, false
+	}
+	groups := []string{}
+	if err := json.Unmarshal([]byte(obj.GetAnnotations()[RequesterGroupsAnnotation]), &groups); err != nil {
+		return "", nil, false
+	}
+	return user, groups, true
+}
+
+//+kubebuilder:webhook:path=/mutate-primer-gitops-io-v1alpha1-requester,mutating=true,failurePolicy=fail,sideEffects=None,groups=primer.gitops.io,resources=extracts;extractsets;imports,verbs=create;update,versions=v1alpha1,name=requester.primer.gitops.io,admissionReviewVersions={v1,v1beta1}
+
+// RequesterRecorder records the user creating an Extract, ExtractSet or
+// Import in the requester annotations. Updates changing the spec record the
+// updating user, so that nobody runs a changed spec with the permissions of
+// the creator. Other updates keep the recorded requester. Only the manager
+// may set or change the annotations itself.
+// +kubebuilder:object:generate=false
+type RequesterRecorder struct {
+	// ManagerUsername is the user the manager runs as. The requester
+	// annotations the manager copies from an ExtractSet to its Extracts are
+	// kept.
+	ManagerUsername string
+}
+
+// Handle sets the requester annotations
+func (h *RequesterRecorder) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	user, groups := req.UserInfo.Username, req.UserInfo.Groups
+	if h.ManagerUsername != "" && user == h.ManagerUsername {
+		if _, _, ok := Requester(obj); ok {
+			return admission.Allowed("requester set by the manager")
+		}
+		// Nothing runs with the permissions of the manager
+		return admission.Denied("the manager must set the requester of the objects it creates")
+	}
+	old := &unstructured.Unstructured{}
+	if req.Operation == admissionv1.Update {
+		if err := old.UnmarshalJSON(req.OldObject.Raw); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	for _, key := range []string{RequesterAnnotation, RequesterGroupsAnnotation} {
+		value, set := obj.GetAnnotations()[key]
+		oldValue, oldSet := old.GetAnnotations()[key]
+		if set && (!oldSet || value != oldValue) {
+			return admission.Denied(fmt.Sprintf("the %s annotation is recorded by the webhook and cannot be set", key))
+		}
+	}
+	if req.Operation == admissionv1.Update {
+		// Whoever changes the spec runs it from then on, other updates such
+		// as requesting a run keep the requester
+		if oldUser, oldGroups, ok := Requester(old); ok && equality.Semantic.DeepEqual(old.Object["spec"], obj.Object["spec"]) {
+			user, groups = oldUser, oldGroups
+		}
+	}
+
+	if groups == nil {
+		groups = []string{}
+	}
+	groupsJSON, err := json.Marshal(groups)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[RequesterAnnotation] = user
+	annotations[RequesterGroupsAnnotation] = string(groupsJSON)
+	obj.SetAnnotations(annotations)
+	current, err := obj.MarshalJSON()
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, current)
+}
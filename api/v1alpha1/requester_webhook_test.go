@@ -0,0 +1,132 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("RequesterRecorder", func() {
+	recorder := &RequesterRecorder{ManagerUsername: "system:serviceaccount:gitops-primer-system:manager"}
+
+	extract := func(branch string, annotations map[string]string) *Extract {
+		return &Extract{
+			TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "Extract"},
+			ObjectMeta: metav1.ObjectMeta{Name: "primer", Namespace: "test", Annotations: annotations},
+			Spec:       ExtractSpec{Repo: "git@github.com:org/repo.git", Branch: branch},
+		}
+	}
+	recorded := func(user string, groups ...string) map[string]string {
+		groupsJSON, err := json.Marshal(append([]string{}, groups...))
+		Expect(err).NotTo(HaveOccurred())
+		return map[string]string{RequesterAnnotation: user, RequesterGroupsAnnotation: string(groupsJSON)}
+	}
+	raw := func(obj *Extract) runtime.RawExtension {
+		data, err := json.Marshal(obj)
+		Expect(err).NotTo(HaveOccurred())
+		return runtime.RawExtension{Raw: data}
+	}
+	review := func(user string, obj, old *Extract) admission.Response {
+		req := admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: user, Groups: []string{"developers"}},
+			Object:    raw(obj),
+		}
+		if old != nil {
+			req.Operation = admissionv1.Update
+			req.OldObject = raw(old)
+		}
+		return recorder.Handle(context.Background(), admission.Request{AdmissionRequest: req})
+	}
+	// patched returns the annotations set by the patch of a response
+	patched := func(response admission.Response) map[string]string {
+		annotations := map[string]string{}
+		for _, op := range response.Patches {
+			switch {
+			case op.Path == "/metadata/annotations":
+				for key, value := range op.Value.(map[string]interface{}) {
+					annotations[key] = value.(string)
+				}
+			case strings.HasPrefix(op.Path, "/metadata/annotations/"):
+				key := strings.NewReplacer("~1", "/", "~0", "~").Replace(strings.TrimPrefix(op.Path, "/metadata/annotations/"))
+				annotations[key] = op.Value.(string)
+			}
+		}
+		return annotations
+	}
+
+	It("records the creating user", func() {
+		response := review("alice", extract("main", nil), nil)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(patched(response)).To(Equal(recorded("alice", "developers")))
+	})
+
+	It("keeps the requester on updates leaving the spec alone", func() {
+		old := extract("main", recorded("alice", "developers"))
+		annotations := recorded("alice", "developers")
+		annotations[RunAnnotation] = "now"
+		response := review("bob", extract("main", annotations), old)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(response.Patches).To(BeEmpty())
+	})
+
+	It("records the user changing the spec", func() {
+		old := extract("main", recorded("alice", "developers"))
+		response := review("bob", extract("stage", recorded("alice", "developers")), old)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(patched(response)).To(Equal(map[string]string{RequesterAnnotation: "bob"}))
+	})
+
+	It("refuses requester annotations set by clients", func() {
+		response := review("alice", extract("main", recorded("root")), nil)
+		Expect(response.Allowed).To(BeFalse())
+		Expect(string(response.Result.Reason)).To(ContainSubstring("cannot be set"))
+
+		old := extract("main", recorded("alice", "developers"))
+		response = review("alice", extract("main", recorded("root", "developers")), old)
+		Expect(response.Allowed).To(BeFalse())
+		response = review("alice", extract("main", recorded("alice", "system:masters")), old)
+		Expect(response.Allowed).To(BeFalse())
+	})
+
+	It("lets the manager set the requester of the objects it creates", func() {
+		response := review(recorder.ManagerUsername, extract("main", recorded("alice", "developers")), nil)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(response.Patches).To(BeEmpty())
+
+		// Also when changing the spec of an object with another requester
+		old := extract("main", recorded("bob"))
+		response = review(recorder.ManagerUsername, extract("stage", recorded("alice", "developers")), old)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(response.Patches).To(BeEmpty())
+	})
+
+	It("refuses objects of the manager without a requester", func() {
+		response := review(recorder.ManagerUsername, extract("main", nil), nil)
+		Expect(response.Allowed).To(BeFalse())
+	})
+})
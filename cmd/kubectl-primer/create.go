@@ -0,0 +1,117 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// create creates an Extract pushing with the SSH key of a file, which is
+// stored in a Secret owned by the Extract
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	repo := flags.String("repo", "", "The git repository to push to.")
+	branch := flags.String("branch", "", "The branch to push to.")
+	email := flags.String("email", "", "The email of the commits.")
+	createBranch := flags.Bool("create-branch", false, "Create the branch if it does not exist.")
+	baseBranch := flags.String("base-branch", "", "The branch a created branch starts from, it is created as orphan otherwise.")
+	key := flags.String("key", "", "The file holding the private SSH key of the repository.")
+	secret := flags.String("secret", "", "The name of the Secret holding the key, defaults to <name>-ssh-key.")
+	knownHosts := flags.String("known-hosts", "", "The known_hosts file trusted for the repository, its host keys are scanned otherwise.")
+	trigger := flags.String("trigger", "", "When extractions run: Once or OnChange.")
+	format := flags.String("format", "", "The output format: flat, kustomize or helm.")
+	retainLogs := flags.Bool("retain-logs", false, "Keep the log of the last extraction.")
+	extractName, err := name(flags, args)
+	if err != nil {
+		return err
+	}
+	if *repo == "" || *branch == "" || *key == "" {
+		return fmt.Errorf("--repo, --branch and --key are required")
+	}
+	if *secret == "" {
+		*secret = extractName + "-ssh-key"
+	}
+	data, err := ioutil.ReadFile(*key)
+	if err != nil {
+		return err
+	}
+	// The extraction reads the key from /keys/id_rsa and the host keys from
+	// /keys/known_hosts
+	secretData := map[string][]byte{"id_rsa": data}
+	if *knownHosts != "" {
+		hosts, err := ioutil.ReadFile(*knownHosts)
+		if err != nil {
+			return err
+		}
+		secretData["known_hosts"] = hosts
+	}
+	c, _, namespace, err := newClients(cf)
+	if err != nil {
+		return err
+	}
+
+	extract := &primerv1alpha1.Extract{
+		ObjectMeta: metav1.ObjectMeta{Name: extractName, Namespace: namespace},
+		Spec: primerv1alpha1.ExtractSpec{
+			Repo:         *repo,
+			Branch:       *branch,
+			Email:        *email,
+			Secret:       *secret,
+			Trigger:      primerv1alpha1.ExtractTrigger(*trigger),
+			RetainLogs:   *retainLogs,
+			CreateBranch: *createBranch,
+			BaseBranch:   *baseBranch,
+		},
+	}
+	if *format != "" {
+		extract.Spec.Output = &primerv1alpha1.ExtractOutput{Format: primerv1alpha1.OutputFormat(*format)}
+	}
+	ctx := context.Background()
+	if err := c.Create(ctx, extract); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "extract.primer.gitops.io/%s created\n", extract.Name)
+
+	// The Secret is owned by the Extract and deleted along with it
+	keySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: *secret, Namespace: namespace},
+		Data:       secretData,
+	}
+	if err := controllerutil.SetOwnerReference(extract, keySecret, scheme); err != nil {
+		return err
+	}
+	if err := c.Create(ctx, keySecret); err != nil {
+		// Leave nothing behind, the Extract cannot run without its key
+		if deleteErr := c.Delete(ctx, extract); deleteErr != nil {
+			return fmt.Errorf("%w, deleting extract.primer.gitops.io/%s failed: %v", err, extract.Name, deleteErr)
+		}
+		fmt.Fprintf(stdout, "extract.primer.gitops.io/%s deleted\n", extract.Name)
+		return err
+	}
+	fmt.Fprintf(stdout, "secret/%s created\n", keySecret.Name)
+	return nil
+}
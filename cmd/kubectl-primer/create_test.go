@@ -0,0 +1,119 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("create", func() {
+	ctx := context.Background()
+	var dir, key string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "kubectl-primer")
+		Expect(err).NotTo(HaveOccurred())
+		key = filepath.Join(dir, "id_rsa")
+		Expect(ioutil.WriteFile(key, []byte("private key"), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("creates the Extract and a Secret it owns", func() {
+		c := fakeClient()
+		output := fakeClients(c, nil)
+
+		Expect(create([]string{"primer", "--repo", "git@example.com:org/repo.git", "--branch", "main", "--key", key, "--format", "kustomize"})).To(Succeed())
+		Expect(output.String()).To(Equal("extract.primer.gitops.io/primer created\nsecret/primer-ssh-key created\n"))
+
+		extract := &primerv1alpha1.Extract{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "primer", Namespace: "test"}, extract)).To(Succeed())
+		Expect(extract.Spec.Repo).To(Equal("git@example.com:org/repo.git"))
+		Expect(extract.Spec.Branch).To(Equal("main"))
+		Expect(extract.Spec.Secret).To(Equal("primer-ssh-key"))
+		Expect(extract.Spec.Output.Format).To(Equal(primerv1alpha1.OutputFormat("kustomize")))
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "primer-ssh-key", Namespace: "test"}, secret)).To(Succeed())
+		Expect(secret.Data).To(Equal(map[string][]byte{"id_rsa": []byte("private key")}))
+		Expect(secret.OwnerReferences).To(HaveLen(1))
+		Expect(secret.OwnerReferences[0].Kind).To(Equal("Extract"))
+		Expect(secret.OwnerReferences[0].Name).To(Equal("primer"))
+	})
+
+	It("stores the known hosts along with the key", func() {
+		c := fakeClient()
+		fakeClients(c, nil)
+		knownHosts := filepath.Join(dir, "known_hosts")
+		Expect(ioutil.WriteFile(knownHosts, []byte("example.com ssh-ed25519 AAAA\n"), 0600)).To(Succeed())
+
+		Expect(create([]string{"primer", "--repo", "git@example.com:org/repo.git", "--branch", "main", "--key", key, "--known-hosts", knownHosts})).To(Succeed())
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "primer-ssh-key", Namespace: "test"}, secret)).To(Succeed())
+		Expect(secret.Data).To(Equal(map[string][]byte{
+			"id_rsa":      []byte("private key"),
+			"known_hosts": []byte("example.com ssh-ed25519 AAAA\n"),
+		}))
+	})
+
+	It("deletes the Extract when the Secret cannot be created", func() {
+		existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "taken", Namespace: "test"}}
+		c := fakeClient(existing)
+		output := fakeClients(c, nil)
+
+		err := create([]string{"primer", "--repo", "git@example.com:org/repo.git", "--branch", "main", "--key", key, "--secret", "taken"})
+		Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+		Expect(output.String()).To(Equal("extract.primer.gitops.io/primer created\nextract.primer.gitops.io/primer deleted\n"))
+
+		err = c.Get(ctx, types.NamespacedName{Name: "primer", Namespace: "test"}, &primerv1alpha1.Extract{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		// The Secret that was already there is left alone
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(existing), &corev1.Secret{})).To(Succeed())
+	})
+
+	It("creates nothing when the Extract cannot be created", func() {
+		existing := &primerv1alpha1.Extract{ObjectMeta: metav1.ObjectMeta{Name: "primer", Namespace: "test"}}
+		c := fakeClient(existing)
+		fakeClients(c, nil)
+
+		err := create([]string{"primer", "--repo", "git@example.com:org/repo.git", "--branch", "main", "--key", key})
+		Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+		err = c.Get(ctx, types.NamespacedName{Name: "primer-ssh-key", Namespace: "test"}, &corev1.Secret{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("requires the repo, branch and key", func() {
+		fakeClients(fakeClient(), nil)
+		Expect(create([]string{"primer", "--repo", "git@example.com:org/repo.git"})).To(MatchError("--repo, --branch and --key are required"))
+	})
+})
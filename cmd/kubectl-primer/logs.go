@@ -0,0 +1,77 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// logs prints the log of the extraction pod of an Extract while it exists,
+// and the retained log of the last extraction once it was cleaned up
+func logs(args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	follow := flags.Bool("f", false, "Follow the log of a running extraction.")
+	extractName, err := name(flags, args)
+	if err != nil {
+		return err
+	}
+	_, clientset, namespace, err := newClients(cf)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name=primer-extract-" + extractName})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) > 0 {
+		// The last pod holds the last attempt
+		pod := pods.Items[0]
+		for _, p := range pods.Items[1:] {
+			if pod.CreationTimestamp.Before(&p.CreationTimestamp) {
+				pod = p
+			}
+		}
+		stream, err := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Follow: *follow}).Stream(ctx)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		_, err = io.Copy(stdout, stream)
+		return err
+	}
+
+	retained, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, primerv1alpha1.LogsName(extractName), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Errorf("the extraction pod of %s was cleaned up and its log was not retained, set spec.retainLogs to keep it", extractName)
+	} else if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, retained.Data[primerv1alpha1.LogsKey])
+	return nil
+}
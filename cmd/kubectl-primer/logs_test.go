@@ -0,0 +1,70 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("logs", func() {
+	pod := func(name string, created time.Time) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "test",
+			Labels:            map[string]string{"job-name": "primer-extract-primer"},
+			CreationTimestamp: metav1.NewTime(created),
+		}}
+	}
+
+	It("prints the log of the extraction pod", func() {
+		now := time.Now()
+		clientset := kubefake.NewSimpleClientset(pod("first", now.Add(-time.Minute)), pod("retry", now))
+		output := fakeClients(fakeClient(), clientset)
+
+		Expect(logs([]string{"primer"})).To(Succeed())
+		// The fake clientset serves the same log for every pod
+		Expect(output.String()).To(Equal("fake logs"))
+		Expect(clientset.Actions()).To(ContainElement(WithTransform(func(action k8stesting.Action) string {
+			return action.GetSubresource()
+		}, Equal("log"))))
+	})
+
+	It("prints the retained log once the pod was cleaned up", func() {
+		retained := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: primerv1alpha1.LogsName("primer"), Namespace: "test"},
+			Data:       map[string]string{primerv1alpha1.LogsKey: "extracted 7 objects\n"},
+		}
+		output := fakeClients(fakeClient(), kubefake.NewSimpleClientset(retained))
+
+		Expect(logs([]string{"primer"})).To(Succeed())
+		Expect(output.String()).To(Equal("extracted 7 objects\n"))
+	})
+
+	It("explains a log that was not retained", func() {
+		fakeClients(fakeClient(), kubefake.NewSimpleClientset())
+		Expect(logs([]string{"primer"})).To(MatchError(ContainSubstring("set spec.retainLogs to keep it")))
+	})
+})
@@ -0,0 +1,154 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-primer manages Extracts as kubectl plugin, kubectl primer <command>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(primerv1alpha1.AddToScheme(scheme))
+}
+
+// commands are the subcommands of the plugin, keyed by name
+var commands = map[string]func(args []string) error{
+	"create":  create,
+	"history": history,
+	"logs":    logs,
+	"run":     run,
+	"status":  status,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl primer %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: kubectl primer <command> NAME [flags]
+
+Commands:
+  create   create an Extract and the Secret holding its SSH key
+  history  list the most recent extractions of an Extract
+  logs     print the log of the last extraction of an Extract
+  run      start another extraction of an Extract
+  status   show the result of the last extraction of an Extract
+`)
+	os.Exit(2)
+}
+
+// clientFlags are the kubeconfig flags shared by all commands
+type clientFlags struct {
+	kubeconfig *string
+	context    *string
+	namespace  *string
+}
+
+func addClientFlags(flags *flag.FlagSet) *clientFlags {
+	f := &clientFlags{
+		kubeconfig: flags.String("kubeconfig", "", "The kubeconfig file, defaults to KUBECONFIG or ~/.kube/config."),
+		context:    flags.String("context", "", "The kubeconfig context, defaults to the current context."),
+		namespace:  new(string),
+	}
+	flags.StringVar(f.namespace, "namespace", "", "The namespace of the Extract, defaults to the namespace of the context.")
+	flags.StringVar(f.namespace, "n", "", "Shorthand for --namespace.")
+	return f
+}
+
+// newClients returns the clients of the commands, tests replace it with fake
+// clients
+var newClients = (*clientFlags).clients
+
+// stdout is where the commands print to
+var stdout io.Writer = os.Stdout
+
+// clients returns the clients of the selected context and the namespace to
+// use
+func (f *clientFlags) clients() (client.Client, kubernetes.Interface, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = *f.kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: *f.context})
+	namespace := *f.namespace
+	if namespace == "" {
+		var err error
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, nil, "", err
+		}
+	}
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return c, clientset, namespace, nil
+}
+
+// parse parses flags given before and after the positional arguments, like
+// kubectl does, and returns the positional arguments
+func parse(flags *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// name returns the single positional argument naming the Extract
+func name(flags *flag.FlagSet, args []string) (string, error) {
+	positional := parse(flags, args)
+	if len(positional) != 1 {
+		return "", fmt.Errorf("expected the name of the Extract")
+	}
+	return positional[0], nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// run requests another extraction by setting the run annotation to the
+// current time. A running extraction is followed by another one.
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	extractName, err := name(flags, args)
+	if err != nil {
+		return err
+	}
+	c, _, namespace, err := newClients(cf)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	extract := &primerv1alpha1.Extract{}
+	if err := c.Get(ctx, types.NamespacedName{Name: extractName, Namespace: namespace}, extract); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(extract.DeepCopy())
+	annotations := extract.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[primerv1alpha1.RunAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	extract.SetAnnotations(annotations)
+	if err := c.Patch(ctx, extract, patch); err != nil {
+		return err
+	}
+	if extract.Status.Completed {
+		fmt.Fprintf(stdout, "extract.primer.gitops.io/%s run requested\n", extract.Name)
+	} else {
+		fmt.Fprintf(stdout, "extract.primer.gitops.io/%s run requested after the running extraction\n", extract.Name)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// getExtract reads the Extract named by the arguments
+func getExtract(command string, args []string) (*primerv1alpha1.Extract, error) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	cf := addClientFlags(flags)
+	extractName, err := name(flags, args)
+	if err != nil {
+		return nil, err
+	}
+	c, _, namespace, err := newClients(cf)
+	if err != nil {
+		return nil, err
+	}
+	extract := &primerv1alpha1.Extract{}
+	err = c.Get(context.Background(), types.NamespacedName{Name: extractName, Namespace: namespace}, extract)
+	return extract, err
+}
+
+// status prints the result of the last extraction of an Extract
+func status(args []string) error {
+	extract, err := getExtract("status", args)
+	if err != nil {
+		return err
+	}
+	s := extract.Status
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", extract.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", extract.Namespace)
+	fmt.Fprintf(w, "Remote:\t%s\n", extract.Spec.Remote())
+	state := "Running"
+	if s.Completed {
+		state = "Completed"
+	}
+	fmt.Fprintf(w, "State:\t%s\n", state)
+	fmt.Fprintf(w, "Last run:\t%s\n", formatTime(s.LastRunTime))
+	if len(s.History) > 0 {
+		last := s.History[0]
+		fmt.Fprintf(w, "Last result:\t%s\n", last.Result)
+		if last.CreatedBranch {
+			fmt.Fprintf(w, "Created branch:\t%s\n", extract.Spec.Branch)
+		}
+		if last.PushRetries > 0 {
+			fmt.Fprintf(w, "Push retries:\t%d\n", last.PushRetries)
+		}
+		if last.Tag != "" {
+			fmt.Fprintf(w, "Tag:\t%s\n", last.Tag)
+		}
+		if last.Message != "" {
+			fmt.Fprintf(w, "Message:\t%s\n", last.Message)
+		}
+	}
+	if s.Revision != "" {
+		fmt.Fprintf(w, "Revision:\t%s\n", s.Revision)
+	}
+	if s.Digest != "" {
+		fmt.Fprintf(w, "Digest:\t%s\n", s.Digest)
+	}
+	fmt.Fprintf(w, "Objects:\t%d\n", s.Objects)
+	fmt.Fprintf(w, "Excluded:\t%d\n", len(s.Excluded))
+	for _, resource := range s.Excluded {
+		fmt.Fprintf(w, "\t%s\n", resource)
+	}
+	failures := 0
+	for _, run := range s.History {
+		if run.Result == primerv1alpha1.RunFailed {
+			failures++
+		}
+	}
+	fmt.Fprintf(w, "Failures:\t%d of the last %d runs\n", failures, len(s.History))
+	if len(s.Conditions) > 0 {
+		fmt.Fprintf(w, "Conditions:\n")
+		for _, condition := range s.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+		}
+	}
+	return w.Flush()
+}
+
+// history lists the most recent extractions of an Extract, newest first
+func history(args []string) error {
+	extract, err := getExtract("history", args)
+	if err != nil {
+		return err
+	}
+	if len(extract.Status.History) == 0 {
+		fmt.Fprintf(stdout, "No extractions of %s finished yet\n", extract.Name)
+		return nil
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tCOMPLETED\tRESULT\tCHANGED\tOBJECTS\tRETRIES\tREVISION\tTAG\tMESSAGE")
+	for _, run := range extract.Status.History {
+		revision := run.Revision
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		message := strings.SplitN(run.Message, "\n", 2)[0]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%d\t%d\t%s\t%s\t%s\n", formatTime(run.StartTime), formatTime(&run.CompletionTime),
+			run.Result, run.Changed, run.Objects, run.PushRetries, revision, run.Tag, message)
+	}
+	return w.Flush()
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil || t.IsZero() {
+		return "<none>"
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("status", func() {
+	It("prints the result of the last extraction", func() {
+		lastRun := metav1.NewTime(time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC))
+		extract := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{Name: "primer", Namespace: "test"},
+			Spec:       primerv1alpha1.ExtractSpec{Repo: "git@example.com:org/repo.git", Branch: "main"},
+			Status: primerv1alpha1.ExtractStatus{
+				Completed:   true,
+				LastRunTime: &lastRun,
+				Revision:    "0123456789abcdef",
+				Objects:     7,
+				Excluded:    []string{"secrets"},
+				History: []primerv1alpha1.ExtractRun{
+					{Result: primerv1alpha1.RunSucceeded, Tag: "2021-05-01", PushRetries: 1},
+					{Result: primerv1alpha1.RunFailed, Message: "push rejected"},
+				},
+			},
+		}
+		output := fakeClients(fakeClient(extract), nil)
+
+		Expect(status([]string{"primer"})).To(Succeed())
+		Expect(output.String()).To(ContainSubstring("Remote:        git@example.com:org/repo.git\n"))
+		Expect(output.String()).To(ContainSubstring("State:         Completed\n"))
+		Expect(output.String()).To(ContainSubstring("Last result:   Succeeded\n"))
+		Expect(output.String()).To(ContainSubstring("Push retries:  1\n"))
+		Expect(output.String()).To(ContainSubstring("Tag:           2021-05-01\n"))
+		Expect(output.String()).To(ContainSubstring("Revision:      0123456789abcdef\n"))
+		Expect(output.String()).To(ContainSubstring("Objects:       7\n"))
+		Expect(output.String()).To(ContainSubstring("Excluded:      1\n               secrets\n"))
+		Expect(output.String()).To(ContainSubstring("Failures:      1 of the last 2 runs\n"))
+	})
+
+	It("fails for a missing Extract", func() {
+		fakeClients(fakeClient(), nil)
+		err := status([]string{"primer"})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
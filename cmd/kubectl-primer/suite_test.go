@@ -0,0 +1,53 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
+)
+
+func TestPlugin(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecsWithDefaultAndCustomReporters(t,
+		"Plugin Suite",
+		[]Reporter{printer.NewlineReporter{}})
+}
+
+// fakeClients makes the commands use fake clients in the namespace test
+// holding objects and returns what they print
+func fakeClients(c client.Client, clientset kubernetes.Interface) *bytes.Buffer {
+	output := &bytes.Buffer{}
+	stdout = output
+	newClients = func(*clientFlags) (client.Client, kubernetes.Interface, string, error) {
+		return c, clientset, "test", nil
+	}
+	return output
+}
+
+func fakeClient(objects ...runtime.Object) client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+}
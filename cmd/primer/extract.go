@@ -0,0 +1,216 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+	"github.com/cooktheryan/gitops-primer/pkg/sink"
+)
+
+// extract runs an extraction from a workstation with the current kubeconfig
+// context, for previewing what the operator would store
+func extract(args []string) error {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig file, defaults to KUBECONFIG or ~/.kube/config.")
+	kubeContext := flags.String("context", "", "The kubeconfig context, defaults to the current context.")
+	namespace := flags.String("namespace", "", "The namespace to extract, defaults to the namespace of the context.")
+	specFile := flags.String("spec", "", "A YAML file holding the Extract or its spec.")
+	repo := flags.String("repo", "", "Overrides the repo of the spec, used by the Argo CD and Flux output.")
+	branch := flags.String("branch", "", "Overrides the branch of the spec, used by the Argo CD and Flux output.")
+	email := flags.String("email", "", "Overrides the email of the spec, used for --commit.")
+	format := flags.String("format", "", "Overrides the output format of the spec: flat, kustomize or helm.")
+	secretPolicy := flags.String("secret-policy", "", "Overrides the secret policy of the spec.")
+	rf := &renderFlags{
+		name:          flags.String("name", "", "The name of the Extract, used by the path template of the spec."),
+		ageRecipients: flags.String("age-recipients", "", "The file holding the age recipients of the SOPS secret policy."),
+		sealingCert:   flags.String("sealing-cert", "", "The certificate of the SealedSecrets secret policy."),
+	}
+	output := flags.String("output", "", "The directory to write to.")
+	commit := flags.Bool("commit", false, "Commit the files to the git repository checked out at --output.")
+	diff := flags.Bool("diff", false, "Show the changes to the checkout at --output instead of writing them.")
+	flags.Parse(args)
+	if *output == "" {
+		return fmt.Errorf("--output is required")
+	}
+	if *commit && *diff {
+		return fmt.Errorf("--commit and --diff are mutually exclusive")
+	}
+
+	spec := &primerv1alpha1.ExtractSpec{}
+	if *specFile != "" {
+		var err error
+		if spec, err = specFromFile(*specFile); err != nil {
+			return err
+		}
+	}
+	overrideString(&spec.Repo, *repo)
+	overrideString(&spec.Branch, *branch)
+	overrideString(&spec.Email, *email)
+	if *format != "" {
+		if spec.Output == nil {
+			spec.Output = &primerv1alpha1.ExtractOutput{}
+		}
+		spec.Output.Format = primerv1alpha1.OutputFormat(*format)
+	}
+	if *secretPolicy != "" {
+		if spec.Secrets == nil {
+			spec.Secrets = &primerv1alpha1.SecretsSpec{}
+		}
+		spec.Secrets.Policy = primerv1alpha1.SecretPolicy(*secretPolicy)
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = *kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: *kubeContext})
+	if *namespace == "" {
+		contextNamespace, _, err := clientConfig.Namespace()
+		if err != nil {
+			return err
+		}
+		*namespace = spec.SourceNamespace(contextNamespace)
+	}
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
+	}
+	resources, err := discoveryClient.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	objs, skipped, err := export.Fetch(ctx, client, resources, *namespace)
+	if err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped resources that cannot be listed: %s\n", strings.Join(skipped, ", "))
+	}
+	tree, err := rf.run(objs, *namespace, spec)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *diff:
+		return diffTree(tree, *output)
+	case *commit:
+		result, err := (&sink.Git{Email: spec.Email, Dir: *output}).Commit(ctx, tree)
+		if err != nil {
+			return err
+		}
+		if !result.Changed {
+			fmt.Printf("No changes to commit in %s\n", result.Location)
+		} else {
+			fmt.Printf("Committed the extraction in %s\n", result.Location)
+		}
+		return nil
+	}
+	return tree.Write(*output)
+}
+
+func overrideString(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+// diffTree prints the changes writing the tree to checkout would make. The
+// files of the tree and its owned directories are copied from the checkout
+// twice, the tree is written to the second copy and both are compared with
+// git diff.
+func diffTree(tree *export.Tree, checkout string) error {
+	tmp, err := ioutil.TempDir("", "primer-diff")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	paths := append(append([]string{}, tree.Owned...), tree.Paths()...)
+	for _, side := range []string{"a", "b"} {
+		for _, p := range paths {
+			if err := copyPath(filepath.Join(checkout, filepath.FromSlash(p)), filepath.Join(tmp, side, filepath.FromSlash(p))); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Join(tmp, side), 0755); err != nil {
+			return err
+		}
+	}
+	if err := tree.Write(filepath.Join(tmp, "b")); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", "diff", "--no-index", "--no-prefix", "a", "b")
+	cmd.Dir = tmp
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		// git diff exits with 1 when there are differences
+		return nil
+	}
+	if err == nil {
+		fmt.Printf("No changes to %s\n", checkout)
+	}
+	return err
+}
+
+// copyPath copies a file or directory, doing nothing when src does not exist
+func copyPath(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == src {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, strings.TrimPrefix(p, src))
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+}
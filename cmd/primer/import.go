@@ -0,0 +1,165 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/restore"
+)
+
+// importNamespace clones the repository of an Import, applies its objects
+// and records the result of every object in the status of the Import
+func importNamespace(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	name := flags.String("name", os.Getenv("IMPORT_NAME"), "The name of the Import.")
+	namespace := flags.String("namespace", os.Getenv("NAMESPACE"), "The namespace of the Import.")
+	workdir := flags.String("workdir", "/repo", "The directory the repository is cloned to.")
+	flags.Parse(args)
+	if *name == "" || *namespace == "" {
+		return fmt.Errorf("--name and --namespace are required")
+	}
+
+	ctx := context.Background()
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return err
+	}
+	scheme := runtime.NewScheme()
+	if err := primerv1alpha1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	key := types.NamespacedName{Name: *name, Namespace: *namespace}
+	imp := &primerv1alpha1.Import{}
+	if err := c.Get(ctx, key, imp); err != nil {
+		return err
+	}
+
+	revision, err := clone(imp.Spec, *workdir)
+	if err != nil {
+		return err
+	}
+	objs, err := restore.Load(*workdir, imp.Spec.Path)
+	if err != nil {
+		return err
+	}
+	restore.Order(objs)
+
+	applier, err := newApplier(config, imp)
+	if err != nil {
+		return err
+	}
+	results := applier.Apply(ctx, objs)
+	if imp.Spec.Prune {
+		results = append(results, applier.Prune(ctx, imp.Status.Resources, results)...)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, key, imp); err != nil {
+			return err
+		}
+		imp.Status.Revision = revision
+		imp.Status.Resources = results
+		return c.Status().Update(ctx, imp)
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		fmt.Printf("%s %s/%s %s %s\n", result.Result, strings.ToLower(result.Kind), result.Name, result.Namespace, result.Message)
+		if result.Result == primerv1alpha1.ImportFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d objects failed", failed, len(results))
+	}
+	return nil
+}
+
+// newApplier returns an applier for the target namespace of the Import
+func newApplier(config *rest.Config, imp *primerv1alpha1.Import) (*restore.Applier, error) {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	target := imp.Spec.TargetNamespace
+	if target == "" {
+		target = imp.Namespace
+	}
+	return &restore.Applier{
+		Client:    dynamicClient,
+		Mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		Namespace: target,
+		Import:    imp.Name,
+	}, nil
+}
+
+// clone checks out the revision of the Import and returns the commit
+func clone(spec primerv1alpha1.ImportSpec, dir string) (string, error) {
+	args := []string{"clone", "-q"}
+	if spec.Branch != "" {
+		args = append(args, "--branch", spec.Branch)
+	}
+	if _, err := git("", append(args, spec.Repo, dir)...); err != nil {
+		return "", err
+	}
+	if spec.Revision != "" {
+		if _, err := git(dir, "checkout", "-q", spec.Revision); err != nil {
+			return "", err
+		}
+	}
+	commit, err := git(dir, "rev-parse", "HEAD")
+	return strings.TrimSpace(commit), err
+}
+
+// git runs a git command in dir and returns its output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// primer runs the Go steps of extractions and imports
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands are the subcommands of primer, keyed by name
+var commands = map[string]func(args []string) error{
+	"extract": extract,
+	"import":  importNamespace,
+	"offline": offline,
+	"render":  render,
+	"store":   store,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "primer %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: primer <command> [flags]
+
+Commands:
+  extract  extract a namespace with the current kubeconfig context locally
+  import   apply the objects of an Import to its target namespace
+  offline  lay out dumps of a namespace, such as must-gather output, locally
+  render   lay out exported objects in a directory
+  store    lay out exported objects and store them in the configured sink
+`)
+	os.Exit(2)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/sink"
+)
+
+// offline lays out dumps of a namespace, such as must-gather output or the
+// output of kubectl get -o yaml, without access to the cluster
+func offline(args []string) error {
+	flags := flag.NewFlagSet("offline", flag.ExitOnError)
+	rf := addRenderFlags(flags)
+	specFile := flags.String("spec", "", "A YAML file holding the Extract or its spec, defaults to EXTRACT_SPEC.")
+	output := flags.String("output", "", "The directory to write to.")
+	commit := flags.Bool("commit", false, "Commit the files to the git repository checked out at --output.")
+	flags.Parse(args)
+	if *output == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	var spec *primerv1alpha1.ExtractSpec
+	var err error
+	if *specFile != "" {
+		spec, err = specFromFile(*specFile)
+	} else {
+		spec, err = specFromEnv()
+	}
+	if err != nil {
+		return err
+	}
+	tree, err := rf.tree(spec)
+	if err != nil {
+		return err
+	}
+	if !*commit {
+		return tree.Write(*output)
+	}
+
+	git := &sink.Git{Email: spec.Email, Dir: *output}
+	result, err := git.Commit(context.Background(), tree)
+	if err != nil {
+		return err
+	}
+	if !result.Changed {
+		fmt.Printf("No changes to commit in %s\n", result.Location)
+	} else {
+		fmt.Printf("Committed the extraction in %s\n", result.Location)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// renderFlags are the flags of the commands rendering exported objects
+type renderFlags struct {
+	input         *string
+	namespace     *string
+	name          *string
+	ageRecipients *string
+	sealingCert   *string
+}
+
+func addRenderFlags(flags *flag.FlagSet) *renderFlags {
+	return &renderFlags{
+		input:         flags.String("input", "", "The directory, file or tarball holding the exported objects."),
+		namespace:     flags.String("namespace", os.Getenv("NAMESPACE"), "The namespace the objects were exported from."),
+		name:          flags.String("name", os.Getenv("EXTRACT_NAME"), "The name of the Extract, used by the path template of the spec."),
+		ageRecipients: flags.String("age-recipients", "/etc/primer/age/recipients", "The file holding the age recipients of the SOPS secret policy."),
+		sealingCert:   flags.String("sealing-cert", "/etc/primer/sealed-secrets/cert.pem", "The certificate of the SealedSecrets secret policy."),
+	}
+}
+
+// tree renders the exported objects as configured by the Extract spec
+func (f *renderFlags) tree(spec *primerv1alpha1.ExtractSpec) (*export.Tree, error) {
+	if *f.input == "" || *f.namespace == "" {
+		return nil, fmt.Errorf("--input and --namespace are required")
+	}
+	objs, err := loadInput(*f.input)
+	if err != nil {
+		return nil, err
+	}
+	// Dumps of a whole cluster hold other namespaces as well
+	objs = export.InNamespace(objs, *f.namespace)
+	return f.run(objs, *f.namespace, spec)
+}
+
+// run renders objs of namespace with the keys needed by the secret policy
+// below the expanded path of the spec
+func (f *renderFlags) run(objs []*unstructured.Unstructured, namespace string, spec *primerv1alpha1.ExtractSpec) (*export.Tree, error) {
+	keys, err := readKeys(spec, *f.ageRecipients, *f.sealingCert)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Path != "" {
+		rendered := *spec
+		vars := export.PathVars{Namespace: namespace, Name: *f.name, ClusterName: spec.ClusterName()}
+		if rendered.Path, err = export.RenderPath(spec, vars); err != nil {
+			return nil, err
+		}
+		spec = &rendered
+	}
+	return export.Run(objs, namespace, spec, keys)
+}
+
+// loadInput reads the objects of a directory, a manifest or a tarball
+func loadInput(input string) ([]*unstructured.Unstructured, error) {
+	name := strings.ToLower(input)
+	if !strings.HasSuffix(name, ".tar") && !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".tgz") {
+		return export.Load(input)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return export.LoadArchive(f)
+}
+
+// render lays out the objects exported by crane in a directory
+func render(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	rf := addRenderFlags(flags)
+	output := flags.String("output", "", "The directory to write to.")
+	flags.Parse(args)
+	if *output == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	spec, err := specFromEnv()
+	if err != nil {
+		return err
+	}
+	tree, err := rf.tree(spec)
+	if err != nil {
+		return err
+	}
+	return tree.Write(*output)
+}
+
+// readKeys reads the key material needed by the secret policy of the spec
+func readKeys(spec *primerv1alpha1.ExtractSpec, ageRecipients, sealingCert string) (export.Keys, error) {
+	keys := export.Keys{}
+	if spec.Secrets == nil {
+		return keys, nil
+	}
+	var err error
+	switch spec.Secrets.Policy {
+	case primerv1alpha1.SecretPolicySOPS:
+		keys.AgeRecipients, err = ioutil.ReadFile(ageRecipients)
+	case primerv1alpha1.SecretPolicySealedSecrets:
+		keys.SealingCertificate, err = ioutil.ReadFile(sealingCert)
+	}
+	return keys, err
+}
+
+// specFromFile reads an Extract spec from a YAML file holding either the spec
+// or a whole Extract
+func specFromFile(file string) (*primerv1alpha1.ExtractSpec, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	extract := &primerv1alpha1.Extract{}
+	if err := yaml.Unmarshal(data, extract); err != nil {
+		return nil, fmt.Errorf("invalid spec in %s: %w", file, err)
+	}
+	if extract.Kind == "Extract" {
+		return &extract.Spec, nil
+	}
+	spec := &primerv1alpha1.ExtractSpec{}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, fmt.Errorf("invalid spec in %s: %w", file, err)
+	}
+	return spec, nil
+}
+
+// specFromEnv reads the Extract spec the controller passes to the Job
+func specFromEnv() (*primerv1alpha1.ExtractSpec, error) {
+	spec := &primerv1alpha1.ExtractSpec{}
+	data := os.Getenv("EXTRACT_SPEC")
+	if data == "" {
+		return spec, nil
+	}
+	if err := json.Unmarshal([]byte(data), spec); err != nil {
+		return nil, fmt.Errorf("invalid EXTRACT_SPEC: %w", err)
+	}
+	return spec, nil
+}
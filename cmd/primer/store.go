@@ -0,0 +1,188 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/sink"
+)
+
+// store renders the objects exported by crane and stores them in the sink
+// configured by the Extract
+func store(args []string) error {
+	flags := flag.NewFlagSet("store", flag.ExitOnError)
+	rf := addRenderFlags(flags)
+	workdir := flags.String("workdir", "/repo", "The directory the git sink clones the repository to.")
+	registryConfig := flags.String("registry-config", "/etc/primer/registry/.dockerconfigjson", "The registry credentials of the oci sink.")
+	outputDir := flags.String("output-dir", "/output", "The directory the pvc sink writes to.")
+	resultFile := flags.String("result", "", "The file the result is written to as JSON, such as /dev/termination-log.")
+	flags.Parse(args)
+
+	spec, err := specFromEnv()
+	if err != nil {
+		return err
+	}
+	tree, err := rf.tree(spec)
+	if err != nil {
+		return err
+	}
+	s, err := newSink(spec, *rf.namespace, *workdir, *registryConfig, *outputDir)
+	if err != nil {
+		return err
+	}
+	result, err := s.Store(context.Background(), tree)
+	if err != nil {
+		// Report the failure along with what was attempted
+		if result == nil {
+			result = &sink.Result{}
+		}
+		result.Error = err.Error()
+		if len(result.Error) > maxErrorLength {
+			result.Error = result.Error[:maxErrorLength]
+		}
+		writeResult(*resultFile, result)
+		return err
+	}
+	result.Objects = tree.Objects
+	if result.Error != "" {
+		// Stored, but something after storing failed, such as tagging
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", result.Error)
+		if len(result.Error) > maxErrorLength {
+			result.Error = result.Error[:maxErrorLength]
+		}
+	}
+	if !result.Changed {
+		fmt.Printf("No changes to store in %s\n", result.Location)
+	} else {
+		fmt.Printf("Stored the extraction in %s\n", result.Location)
+	}
+	return writeResult(*resultFile, result)
+}
+
+// maxErrorLength keeps the result within the 4096 bytes of termination
+// messages
+const maxErrorLength = 2048
+
+// writeResult writes the result as JSON to file, if any
+func writeResult(file string, result *sink.Result) error {
+	if file == "" {
+		return nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// newSink returns the sink selected by the spec. Credentials and the name of
+// the Extract are read from the environment.
+func newSink(spec *primerv1alpha1.ExtractSpec, namespace, workdir, registryConfig, outputDir string) (sink.Sink, error) {
+	sinkType := primerv1alpha1.SinkGit
+	if spec.Sink != nil && spec.Sink.Type != "" {
+		sinkType = spec.Sink.Type
+	}
+	switch sinkType {
+	case primerv1alpha1.SinkGit:
+		git := &sink.Git{
+			Repo:         spec.Repo,
+			Branch:       spec.Branch,
+			Email:        spec.Email,
+			Dir:          workdir,
+			CreateBranch: spec.CreateBranch,
+			BaseBranch:   spec.BaseBranch,
+		}
+		if spec.Tagging != nil {
+			// EXTRACT_RUN numbers the extractions of the Extract
+			run, _ := strconv.Atoi(os.Getenv("EXTRACT_RUN"))
+			git.Tagging = &sink.Tagging{
+				Name:      spec.Tagging.Name,
+				Retention: int(spec.Tagging.Retention),
+				Owner:     os.Getenv("NAMESPACE") + "/" + os.Getenv("EXTRACT_NAME"),
+				Vars: sink.TagVars{
+					Run:       run,
+					Namespace: namespace,
+					Name:      os.Getenv("EXTRACT_NAME"),
+				},
+			}
+		}
+		return git, nil
+	case primerv1alpha1.SinkS3:
+		if spec.Sink.S3 == nil {
+			return nil, fmt.Errorf("the s3 sink is not configured")
+		}
+		s3 := spec.Sink.S3
+		return &sink.S3{
+			Endpoint:        s3.Endpoint,
+			Region:          s3.Region,
+			Bucket:          s3.Bucket,
+			Prefix:          path.Join(s3.Prefix, spec.ClusterDirectory()),
+			Namespace:       namespace,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			Retention:       int(s3.Retention),
+		}, nil
+	case primerv1alpha1.SinkOCI:
+		if spec.Sink.OCI == nil {
+			return nil, fmt.Errorf("the oci sink is not configured")
+		}
+		oci := &sink.OCI{
+			Repository: spec.Sink.OCI.Repository,
+			Namespace:  namespace,
+			Insecure:   spec.Sink.OCI.Insecure,
+		}
+		if spec.Sink.OCI.CredentialsSecret != "" {
+			config, err := ioutil.ReadFile(registryConfig)
+			if err != nil {
+				return nil, err
+			}
+			oci.DockerConfig = config
+		}
+		return oci, nil
+	case primerv1alpha1.SinkPVC:
+		return &sink.Directory{Dir: outputDir}, nil
+	case primerv1alpha1.SinkConfigMap:
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		// The output ConfigMap lives next to the Extract, which is not the
+		// extracted namespace when the source is another cluster
+		return &sink.ConfigMap{
+			Client:    client,
+			Namespace: os.Getenv("NAMESPACE"),
+			Name:      primerv1alpha1.OutputName(os.Getenv("EXTRACT_NAME")),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown sink %q", sinkType)
+}
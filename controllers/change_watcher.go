@@ -0,0 +1,295 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+const (
+	defaultDebounce    = 30 * time.Second
+	defaultMinInterval = 5 * time.Minute
+)
+
+// defaultWatchedResources are watched when an OnChange Extract does not list
+// any resources
+var defaultWatchedResources = []primerv1alpha1.WatchedResource{
+	{APIVersion: "apps/v1", Kind: "Deployment"},
+	{APIVersion: "apps/v1", Kind: "StatefulSet"},
+	{APIVersion: "apps/v1", Kind: "DaemonSet"},
+	{APIVersion: "v1", Kind: "Service"},
+	{APIVersion: "v1", Kind: "ConfigMap"},
+	{APIVersion: "v1", Kind: "Secret"},
+	{APIVersion: "v1", Kind: "ServiceAccount"},
+	{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+	{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+	{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+}
+
+// changeWatcher runs dynamic informers for the namespaces of OnChange
+// Extracts. Changes are collected for the debounce window, after which the
+// Extract is marked pending and queued through events.
+type changeWatcher struct {
+	log     logr.Logger
+	client  dynamic.Interface
+	mapper  meta.RESTMapper
+	events  chan event.GenericEvent
+	mu      sync.Mutex
+	watches map[types.NamespacedName]*namespaceWatch
+	clock   clock.Clock
+}
+
+// namespaceWatch is the informer set and debounce state of one Extract
+type namespaceWatch struct {
+	resources []primerv1alpha1.WatchedResource
+	debounce  time.Duration
+	started   time.Time
+	stop      chan struct{}
+	timer     clock.Timer
+	pending   bool
+}
+
+func newChangeWatcher(log logr.Logger, client dynamic.Interface, mapper meta.RESTMapper) *changeWatcher {
+	return &changeWatcher{
+		log:     log,
+		client:  client,
+		mapper:  mapper,
+		events:  make(chan event.GenericEvent),
+		watches: map[types.NamespacedName]*namespaceWatch{},
+		clock:   clock.RealClock{},
+	}
+}
+
+// update starts, restarts or stops the watch of an Extract to match its spec
+func (w *changeWatcher) update(m *primerv1alpha1.Extract) {
+	key := types.NamespacedName{Name: m.Name, Namespace: m.Namespace}
+	// The informers only reach the cluster of the manager
+	if m.Spec.Trigger != primerv1alpha1.TriggerOnChange || m.Spec.Source != nil {
+		w.remove(key)
+		return
+	}
+
+	resources := defaultWatchedResources
+	debounce := defaultDebounce
+	if m.Spec.OnChange != nil {
+		if len(m.Spec.OnChange.Resources) > 0 {
+			resources = m.Spec.OnChange.Resources
+		}
+		if m.Spec.OnChange.Debounce != nil {
+			debounce = m.Spec.OnChange.Debounce.Duration
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if existing, ok := w.watches[key]; ok {
+		if reflect.DeepEqual(existing.resources, resources) && existing.debounce == debounce {
+			return
+		}
+		w.stopLocked(key)
+	}
+
+	nw := &namespaceWatch{
+		resources: resources,
+		debounce:  debounce,
+		started:   w.clock.Now(),
+		stop:      make(chan struct{}),
+	}
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(w.client, 0, m.Namespace, nil)
+	for _, resource := range resources {
+		gvk := schema.FromAPIVersionAndKind(resource.APIVersion, resource.Kind)
+		mapping, err := w.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			w.log.Error(err, "Unable to watch resource", "Extract", key, "apiVersion", resource.APIVersion, "kind", resource.Kind)
+			continue
+		}
+		factory.ForResource(mapping.Resource).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				w.added(key, nw, obj)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				w.updated(key, nw, oldObj, newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				w.changed(key, nw, obj)
+			},
+		})
+	}
+	w.watches[key] = nw
+	factory.Start(nw.stop)
+	w.log.Info("Watching namespace for changes", "Extract", key)
+}
+
+// added handles objects created in the namespace, objects listed when the
+// informer starts are not changes
+func (w *changeWatcher) added(key types.NamespacedName, nw *namespaceWatch, obj interface{}) {
+	if o, ok := obj.(metav1.Object); ok && o.GetCreationTimestamp().Time.Before(nw.started) {
+		return
+	}
+	w.changed(key, nw, obj)
+}
+
+// updated handles updated objects, updates that do not change what would be
+// exported, such as status updates, are not changes
+func (w *changeWatcher) updated(key types.NamespacedName, nw *namespaceWatch, oldObj, newObj interface{}) {
+	o, okOld := oldObj.(*unstructured.Unstructured)
+	n, okNew := newObj.(*unstructured.Unstructured)
+	if okOld && okNew {
+		if o.GetResourceVersion() == n.GetResourceVersion() {
+			return
+		}
+		oldExport, newExport := o.DeepCopy(), n.DeepCopy()
+		export.Sanitize(oldExport)
+		export.Sanitize(newExport)
+		if equality.Semantic.DeepEqual(oldExport.Object, newExport.Object) {
+			return
+		}
+	}
+	w.changed(key, nw, newObj)
+}
+
+// changed opens a debounce window unless one is already open or an
+// extraction is already pending
+func (w *changeWatcher) changed(key types.NamespacedName, nw *namespaceWatch, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if o, ok := obj.(metav1.Object); ok && isPrimerObject(o) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watches[key] != nw || nw.timer != nil || nw.pending {
+		return
+	}
+	timer := w.clock.NewTimer(nw.debounce)
+	nw.timer = timer
+	go func() {
+		select {
+		case <-timer.C():
+		case <-nw.stop:
+			return
+		}
+		w.mu.Lock()
+		if w.watches[key] != nw {
+			w.mu.Unlock()
+			return
+		}
+		nw.timer = nil
+		nw.pending = true
+		w.mu.Unlock()
+
+		extract := &primerv1alpha1.Extract{}
+		extract.Name = key.Name
+		extract.Namespace = key.Namespace
+		select {
+		case w.events <- event.GenericEvent{Object: extract}:
+		case <-nw.stop:
+		}
+	}()
+}
+
+// isPrimerObject reports whether an object belongs to an extraction so that
+// running an extraction does not trigger the next one
+func isPrimerObject(o metav1.Object) bool {
+	if strings.HasPrefix(o.GetName(), "primer-extract-") {
+		return true
+	}
+	if strings.HasPrefix(o.GetLabels()["job-name"], "primer-extract-") {
+		return true
+	}
+	for _, ref := range o.GetOwnerReferences() {
+		if ref.Kind == "Extract" && strings.HasPrefix(ref.APIVersion, primerv1alpha1.GroupVersion.Group+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pending reports whether changes are waiting for an extraction
+func (w *changeWatcher) pending(key types.NamespacedName) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	nw, ok := w.watches[key]
+	return ok && nw.pending
+}
+
+// clear resets the pending state once an extraction has been started
+func (w *changeWatcher) clear(key types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if nw, ok := w.watches[key]; ok {
+		nw.pending = false
+	}
+}
+
+// remove stops watching the namespace of an Extract
+func (w *changeWatcher) remove(key types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopLocked(key)
+}
+
+func (w *changeWatcher) stopLocked(key types.NamespacedName) {
+	nw, ok := w.watches[key]
+	if !ok {
+		return
+	}
+	if nw.timer != nil {
+		nw.timer.Stop()
+	}
+	close(nw.stop)
+	delete(w.watches, key)
+	w.log.Info("Stopped watching namespace for changes", "Extract", key)
+}
+
+// wait returns how long pending changes of an Extract wait for the minimum
+// interval since its last extraction
+func (w *changeWatcher) wait(m *primerv1alpha1.Extract) time.Duration {
+	if m.Status.LastRunTime == nil {
+		return 0
+	}
+	return m.Status.LastRunTime.Add(minInterval(m)).Sub(w.clock.Now())
+}
+
+// minInterval returns the minimum time between two extractions of an Extract
+func minInterval(m *primerv1alpha1.Extract) time.Duration {
+	if m.Spec.OnChange != nil && m.Spec.OnChange.MinInterval != nil {
+		return m.Spec.OnChange.MinInterval.Duration
+	}
+	return defaultMinInterval
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("changeWatcher", func() {
+	key := types.NamespacedName{Name: "primer", Namespace: "test"}
+	var (
+		fakeClock *clock.FakeClock
+		w         *changeWatcher
+		nw        *namespaceWatch
+	)
+
+	BeforeEach(func() {
+		fakeClock = clock.NewFakeClock(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+		w = newChangeWatcher(ctrl.Log.WithName("changes"), nil, nil)
+		w.clock = fakeClock
+		// A watch without informers, the tests call its handlers
+		nw = &namespaceWatch{debounce: 30 * time.Second, started: fakeClock.Now(), stop: make(chan struct{})}
+		w.watches[key] = nw
+	})
+
+	AfterEach(func() {
+		w.remove(key)
+	})
+
+	deployment := func(resourceVersion string, generation int64) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      "web",
+				"namespace": "test",
+			},
+			"spec": map[string]interface{}{"replicas": int64(1)},
+		}}
+		obj.SetResourceVersion(resourceVersion)
+		obj.SetGeneration(generation)
+		return obj
+	}
+	debouncing := func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return nw.timer != nil
+	}
+
+	It("counts label and annotation updates without a new generation", func() {
+		old := deployment("1", 1)
+		labelled := deployment("2", 1)
+		labelled.SetLabels(map[string]string{"team": "shop"})
+		w.updated(key, nw, old, labelled)
+		Expect(debouncing()).To(BeTrue())
+
+		w.remove(key)
+		nw = &namespaceWatch{debounce: 30 * time.Second, started: fakeClock.Now(), stop: make(chan struct{})}
+		w.watches[key] = nw
+		annotated := deployment("2", 1)
+		annotated.SetAnnotations(map[string]string{"owner": "shop"})
+		w.updated(key, nw, old, annotated)
+		Expect(debouncing()).To(BeTrue())
+	})
+
+	It("ignores updates that do not change the export", func() {
+		old := deployment("1", 1)
+		w.updated(key, nw, old, deployment("1", 1))
+		statusOnly := deployment("2", 1)
+		statusOnly.Object["status"] = map[string]interface{}{"readyReplicas": int64(1)}
+		w.updated(key, nw, old, statusOnly)
+		clusterAnnotation := deployment("3", 1)
+		clusterAnnotation.SetAnnotations(map[string]string{"deployment.kubernetes.io/revision": "2"})
+		w.updated(key, nw, old, clusterAnnotation)
+		Expect(debouncing()).To(BeFalse())
+	})
+
+	It("ignores objects listed when the watch starts", func() {
+		listed := deployment("1", 1)
+		listed.SetCreationTimestamp(metav1.NewTime(fakeClock.Now().Add(-time.Hour)))
+		w.added(key, nw, listed)
+		Expect(debouncing()).To(BeFalse())
+
+		created := deployment("2", 1)
+		created.SetCreationTimestamp(metav1.NewTime(fakeClock.Now().Add(time.Second)))
+		w.added(key, nw, created)
+		Expect(debouncing()).To(BeTrue())
+	})
+
+	It("queues the Extract once the debounce window closes", func() {
+		w.changed(key, nw, deployment("1", 1))
+		w.changed(key, nw, deployment("2", 2))
+		Expect(w.pending(key)).To(BeFalse())
+
+		fakeClock.Step(29 * time.Second)
+		Consistently(w.events, 100*time.Millisecond).ShouldNot(Receive())
+		Expect(w.pending(key)).To(BeFalse())
+
+		fakeClock.Step(time.Second)
+		var e event.GenericEvent
+		Eventually(w.events).Should(Receive(&e))
+		Expect(e.Object.GetName()).To(Equal("primer"))
+		Expect(w.pending(key)).To(BeTrue())
+		// The changes of the window queued a single extraction
+		Consistently(w.events, 100*time.Millisecond).ShouldNot(Receive())
+
+		// Changes while pending do not open another window
+		w.changed(key, nw, deployment("3", 3))
+		Expect(debouncing()).To(BeFalse())
+		w.clear(key)
+		Expect(w.pending(key)).To(BeFalse())
+	})
+
+	It("ignores the objects of extractions", func() {
+		job := deployment("1", 1)
+		job.SetName("primer-extract-primer")
+		w.changed(key, nw, job)
+		pod := deployment("1", 1)
+		pod.SetLabels(map[string]string{"job-name": "primer-extract-primer"})
+		w.changed(key, nw, pod)
+		owned := deployment("1", 1)
+		owned.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: primerv1alpha1.GroupVersion.String(), Kind: "Extract", Name: "primer"}})
+		w.changed(key, nw, owned)
+		Expect(debouncing()).To(BeFalse())
+
+		// Owners of other groups named Extract do not count
+		foreign := deployment("1", 1)
+		foreign.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Extract", Name: "primer"}})
+		Expect(isPrimerObject(foreign)).To(BeFalse())
+	})
+
+	It("waits for the minimum interval since the last extraction", func() {
+		lastRun := metav1.NewTime(fakeClock.Now().Add(-time.Minute))
+		extract := &primerv1alpha1.Extract{Status: primerv1alpha1.ExtractStatus{LastRunTime: &lastRun}}
+		Expect(w.wait(extract)).To(Equal(4 * time.Minute))
+
+		extract.Spec.OnChange = &primerv1alpha1.OnChangeSpec{MinInterval: &metav1.Duration{Duration: 30 * time.Second}}
+		Expect(w.wait(extract)).To(BeNumerically("<=", 0))
+
+		extract.Status.LastRunTime = nil
+		Expect(w.wait(extract)).To(BeZero())
+	})
+})
@@ -18,37 +18,81 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/operator-framework/operator-lib/status"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/allowlist"
+	"github.com/cooktheryan/gitops-primer/pkg/sink"
 )
 
 // ExtractReconciler reconciles a Extract object
 type ExtractReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Config is the ConfigMap holding the repository allowlist
+	Config types.NamespacedName
+	// DNS is the Service of the cluster DNS extraction pods may query,
+	// kube-system/kube-dns when empty
+	DNS types.NamespacedName
+
+	// changes watches the namespaces of OnChange Extracts
+	changes *changeWatcher
+	// apiReader reads the pods of extraction Jobs without caching all pods
+	apiReader client.Reader
+	// discovery lists the resources checked against the requester
+	discovery discovery.DiscoveryInterface
+	// clientset reads the logs of extraction pods
+	clientset kubernetes.Interface
+	// queue serializes extractions pushing to the same repository and branch
+	queue *targetQueue
 }
 
+// queuedRequeueInterval is how often queued Extracts check their target
+const queuedRequeueInterval = 30 * time.Second
+
 //+kubebuilder:rbac:groups=primer.gitops.io,resources=extracts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=primer.gitops.io,resources=extracts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=primer.gitops.io,resources=extracts/finalizers,verbs=update
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list
+//+kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=create;update
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;create
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=*,resources=*,verbs=get;list
+//+kubebuilder:rbac:groups=*,resources=*,verbs=get;list;watch
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;create;update;delete
+//+kubebuilder:rbac:groups=core,resources=services;endpoints,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -71,6 +115,8 @@ func (r *ExtractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			log.Info("Extract resource not found. Ignoring since object must be deleted")
+			r.changes.remove(req.NamespacedName)
+			r.queue.release(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -78,20 +124,138 @@ func (r *ExtractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
+	// Start or stop watching the namespace for changes
+	r.changes.update(instance)
+
+	// Run another extraction once changes are pending and the previous one
+	// is far enough in the past
+	if instance.Status.Completed && r.changes.pending(req.NamespacedName) {
+		if wait := r.changes.wait(instance); wait > 0 {
+			log.Info("Changes pending, waiting for the minimum interval", "wait", wait)
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		log.Info("Objects changed, starting a new extraction")
+		instance.Status.Completed = false
+		if err := r.Status().Update(ctx, instance); err != nil {
+			log.Error(err, "Failed to update Extract status")
+			return ctrl.Result{}, err
+		}
+		r.changes.clear(req.NamespacedName)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// Run another extraction when the run annotation changed
+	if instance.Status.Completed && instance.Annotations[primerv1alpha1.RunAnnotation] != instance.Status.RunRequest {
+		log.Info("Run requested, starting a new extraction")
+		instance.Status.Completed = false
+		if err := r.Status().Update(ctx, instance); err != nil {
+			log.Error(err, "Failed to update Extract status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	// Check if the Job already exists, if not create a new one
 	found := &batchv1.Job{}
 	err = r.Get(ctx, types.NamespacedName{Name: "primer-extract-" + instance.Name, Namespace: instance.Namespace}, found)
 	if !instance.Status.Completed && err != nil && errors.IsNotFound(err) {
+		// Extracts only run with the permissions of a recorded requester, any
+		// update of the Extract records one and reconciles again
+		if _, _, ok := primerv1alpha1.Requester(instance); !ok {
+			log.Info("Extract has no requester, not running it")
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, r.setReconciledError(ctx, instance, errNoRequester)
+		}
+		// The webhook refuses incomplete sinks, Extracts created without it
+		// are caught here
+		if err := instance.Spec.ValidateSink(); err != nil {
+			log.Info("Extract sink incomplete, not running it", "Error", err.Error())
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, r.setReconciledError(ctx, instance, err)
+		}
+		// Never push to remotes missing from the allowlist
+		allowed, err := r.remoteAllowed(ctx, instance)
+		if err != nil {
+			log.Error(err, "Failed to check the repository allowlist")
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, err
+		}
+		if !allowed {
+			log.Info("Remote not in the repository allowlist", "Remote", instance.Spec.Remote())
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		// Only one extraction pushes to a repository and branch at a time,
+		// failures before its Job exists release the target again
+		if target := targetKey(instance); target != "" {
+			if ok, holder := r.queue.acquire(target, req.NamespacedName); !ok {
+				log.Info("Waiting for another extraction of the same target", "Target", target, "Holder", holder)
+				changed := instance.Status.Conditions.SetCondition(status.Condition{
+					Type:    primerv1alpha1.ConditionQueued,
+					Status:  corev1.ConditionTrue,
+					Reason:  primerv1alpha1.QueuedReasonWaiting,
+					Message: fmt.Sprintf("Waiting for %s to push to %s", holder, target),
+				})
+				// Polling leaves the status alone while the holder stays
+				if changed {
+					if err := r.Status().Update(ctx, instance); err != nil {
+						log.Error(err, "Failed to update Extract status")
+						return ctrl.Result{}, err
+					}
+				}
+				// Released targets queue the next Extract, polling covers
+				// Extracts waiting since before a restart
+				return ctrl.Result{RequeueAfter: queuedRequeueInterval}, nil
+			}
+		}
+		// The in-cluster sinks write to an object that outlives the Job
+		if err := r.ensureOutput(ctx, instance); err != nil {
+			log.Error(err, "Failed to create the output of the Extract")
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, err
+		}
+		// Restrict the egress of the pod before it starts
+		policy, err := r.networkPolicyForExtract(ctx, instance)
+		if err != nil {
+			log.Error(err, "Failed to generate the NetworkPolicy")
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, err
+		}
+		if err := r.applyNetworkPolicy(ctx, policy); err != nil {
+			log.Error(err, "Failed to create new NetworkPolicy", "NetworkPolicy.Namespace", policy.Namespace, "NetworkPolicy.Name", policy.Name)
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, err
+		}
 		// Define a new job
 		job := r.jobForExtract(instance)
 		log.Info("Creating a new Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
 		err = r.Create(ctx, job)
 		if err != nil {
 			log.Error(err, "Failed to create new Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+			r.queue.release(req.NamespacedName)
+			return ctrl.Result{}, err
+		}
+		now := metav1.Now()
+		instance.Status.LastRunTime = &now
+		instance.Status.RunRequest = instance.Annotations[primerv1alpha1.RunAnnotation]
+		instance.Status.Runs++
+		if instance.Status.Conditions.IsTrueFor(primerv1alpha1.ConditionQueued) {
+			instance.Status.Conditions.SetCondition(status.Condition{
+				Type:    primerv1alpha1.ConditionQueued,
+				Status:  corev1.ConditionFalse,
+				Reason:  primerv1alpha1.QueuedReasonStarted,
+				Message: "Extraction started",
+			})
+		}
+		if err := r.Status().Update(ctx, instance); err != nil {
+			log.Error(err, "Failed to update Extract status")
 			return ctrl.Result{}, err
 		}
 		// Job created successfully - return and requeue
 		return ctrl.Result{Requeue: true}, nil
+	} else if !instance.Status.Completed && err == nil && found.DeletionTimestamp != nil {
+		// The Job of the previous extraction is still being removed
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	} else if instance.Status.Completed {
 		return ctrl.Result{}, nil
 	} else if err != nil {
@@ -125,8 +289,21 @@ func (r *ExtractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	foundRole := &rbacv1.Role{}
 	err = r.Get(ctx, types.NamespacedName{Name: "primer-extract-" + instance.Name, Namespace: instance.Namespace}, foundRole)
 	if !instance.Status.Completed && err != nil && errors.IsNotFound(err) {
+		// Only grant what the requester of the Extract can list
+		rules, excluded, err := r.requesterRules(ctx, instance)
+		if err != nil {
+			log.Error(err, "Failed to check the permissions of the requester")
+			return ctrl.Result{}, err
+		}
+		if !reflect.DeepEqual(instance.Status.Excluded, excluded) {
+			instance.Status.Excluded = excluded
+			if err := r.Status().Update(ctx, instance); err != nil {
+				log.Error(err, "Failed to update Extract status")
+				return ctrl.Result{}, err
+			}
+		}
 		// Define a new Role
-		role := r.roleGenerate(instance)
+		role := r.roleGenerate(instance, rules)
 		log.Info("Creating a new Role", "role.Namespace", role.Namespace, "role.Name", role.Name)
 		err = r.Create(ctx, role)
 		if err != nil {
@@ -167,16 +344,34 @@ func (r *ExtractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		instance.Status.Conditions = status.Conditions{}
 	}
 
-	jobComplete := isJobComplete(found)
+	// A failed extraction finishes the run as well, it is retried by the next
+	// change or run request
+	jobComplete := isJobComplete(found) || isJobFailed(found)
+	if target := targetKey(instance); target != "" && !jobComplete {
+		// Extractions running since before a restart keep their target
+		r.queue.claim(target, req.NamespacedName)
+	}
 	// Update status.Nodes if needed
 	if !reflect.DeepEqual(jobComplete, instance.Status.Completed) {
 		instance.Status.Completed = jobComplete
+		if jobComplete {
+			if resultErr := r.recordRun(ctx, instance, found); resultErr != nil {
+				log.Error(resultErr, "Failed to read the result of the Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+			}
+			if instance.Spec.RetainLogs {
+				if logsErr := r.retainLogs(ctx, instance, found); logsErr != nil {
+					log.Error(logsErr, "Failed to retain the log of the Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+				}
+			}
+		}
 		err := r.Status().Update(ctx, instance)
+		r.queue.release(req.NamespacedName)
 		log.Info("Cleaning up Primer Resources")
 		r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground))
 		r.Delete(ctx, foundRole)
 		r.Delete(ctx, foundRoleBinding)
 		r.Delete(ctx, foundSA)
+		r.Delete(ctx, &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "primer-extract-" + instance.Name, Namespace: instance.Namespace}})
 		if err != nil {
 			log.Error(err, "Failed to update Extract status")
 			return ctrl.Result{}, err
@@ -210,9 +405,28 @@ func (r *ExtractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	return ctrl.Result{}, nil
 }
 
+// sshEnv returns the host and port of an SSH git remote as SSH_HOST and
+// SSH_PORT, which the Job scans for its host key unless the SSH key Secret
+// holds known_hosts
+func sshEnv(repo string) []corev1.EnvVar {
+	if strings.Contains(repo, "://") && !strings.HasPrefix(repo, "ssh://") {
+		return nil
+	}
+	host, port, err := remoteHostPort(primerv1alpha1.ExtractSpec{Repo: repo})
+	if err != nil || host == "" {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{Name: "SSH_HOST", Value: host},
+		{Name: "SSH_PORT", Value: strconv.Itoa(int(port))},
+	}
+}
+
 // jobForExtract returns a instance Job object
 func (r *ExtractReconciler) jobForExtract(m *primerv1alpha1.Extract) *batchv1.Job {
 	mode := int32(0600)
+	// The extraction reads the settings it needs from the spec
+	spec, _ := json.Marshal(m.Spec)
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "primer-extract-" + m.Name,
@@ -232,9 +446,12 @@ func (r *ExtractReconciler) jobForExtract(m *primerv1alpha1.Extract) *batchv1.Jo
 							{Name: "BRANCH", Value: m.Spec.Branch},
 							{Name: "EMAIL", Value: m.Spec.Email},
 							{Name: "NAMESPACE", Value: m.Namespace},
+							{Name: "EXTRACT_NAME", Value: m.Name},
+							{Name: "EXTRACT_SPEC", Value: string(spec)},
+							{Name: "SOURCE_NAMESPACE", Value: m.Spec.SourceNamespace(m.Namespace)},
+							{Name: "EXTRACT_RUN", Value: strconv.Itoa(int(m.Status.Runs) + 1)},
 						},
 						VolumeMounts: []corev1.VolumeMount{
-							{Name: "sshkeys", MountPath: "/keys"},
 							{Name: "repo", MountPath: "/repo"},
 						},
 					}},
@@ -243,21 +460,190 @@ func (r *ExtractReconciler) jobForExtract(m *primerv1alpha1.Extract) *batchv1.Jo
 							EmptyDir: &corev1.EmptyDirVolumeSource{},
 						},
 						},
-						{Name: "sshkeys", VolumeSource: corev1.VolumeSource{
-							Secret: &corev1.SecretVolumeSource{
-								SecretName:  m.Spec.Secret,
-								DefaultMode: &mode,
-							}},
-						},
 					},
 				},
 			},
 		},
 	}
+	pod := &job.Spec.Template.Spec
+	if m.Spec.Secret != "" {
+		pod.Volumes = append(pod.Volumes, corev1.Volume{Name: "sshkeys", VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  m.Spec.Secret,
+				DefaultMode: &mode,
+			}},
+		})
+		pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "sshkeys", MountPath: "/keys"})
+		pod.Containers[0].Env = append(pod.Containers[0].Env, sshEnv(m.Spec.Remote())...)
+	}
+	if m.Spec.Sink != nil && m.Spec.Sink.Type == primerv1alpha1.SinkS3 && m.Spec.Sink.S3 != nil {
+		// The credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+		pod.Containers[0].EnvFrom = append(pod.Containers[0].EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: m.Spec.Sink.S3.CredentialsSecret},
+			},
+		})
+	}
+	if m.Spec.Sink != nil && m.Spec.Sink.Type == primerv1alpha1.SinkOCI && m.Spec.Sink.OCI != nil && m.Spec.Sink.OCI.CredentialsSecret != "" {
+		pod.Volumes = append(pod.Volumes, corev1.Volume{Name: "registry-credentials", VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: m.Spec.Sink.OCI.CredentialsSecret,
+				Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: corev1.DockerConfigJsonKey}},
+			}},
+		})
+		pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "registry-credentials", MountPath: "/etc/primer/registry"})
+	}
+	if m.Spec.Sink != nil && m.Spec.Sink.Type == primerv1alpha1.SinkPVC {
+		pod.Volumes = append(pod.Volumes, corev1.Volume{Name: "output", VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: primerv1alpha1.OutputName(m.Name),
+			}},
+		})
+		pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "output", MountPath: "/output"})
+	}
+	if m.Spec.Source != nil {
+		// The extraction reads the namespace from the cluster of the kubeconfig
+		ref := m.Spec.Source.KubeconfigSecretRef
+		pod.Volumes = append(pod.Volumes, corev1.Volume{Name: "kubeconfig", VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  ref.Name,
+				Items:       []corev1.KeyToPath{{Key: ref.Key, Path: "kubeconfig"}},
+				DefaultMode: &mode,
+			}},
+		})
+		pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "kubeconfig", MountPath: "/etc/primer/kubeconfig"})
+	}
+	addSecretKeyVolumes(job, m.Spec.Secrets)
 	ctrl.SetControllerReference(m, job, r.Scheme)
 	return job
 }
 
+// addSecretKeyVolumes mounts the ConfigMap keys needed by the secret policy
+// where primer render reads them
+func addSecretKeyVolumes(job *batchv1.Job, secrets *primerv1alpha1.SecretsSpec) {
+	if secrets == nil {
+		return
+	}
+	var selector *corev1.ConfigMapKeySelector
+	var name, dir, file string
+	switch {
+	case secrets.Policy == primerv1alpha1.SecretPolicySOPS && secrets.SOPS != nil:
+		selector, name, dir, file = &secrets.SOPS.AgeRecipients, "age-recipients", "/etc/primer/age", "recipients"
+	case secrets.Policy == primerv1alpha1.SecretPolicySealedSecrets && secrets.SealedSecrets != nil:
+		selector, name, dir, file = &secrets.SealedSecrets.Certificate, "sealing-cert", "/etc/primer/sealed-secrets", "cert.pem"
+	default:
+		return
+	}
+	pod := &job.Spec.Template.Spec
+	pod.Volumes = append(pod.Volumes, corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: selector.LocalObjectReference,
+			Items:                []corev1.KeyToPath{{Key: selector.Key, Path: file}},
+		}},
+	})
+	pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, corev1.VolumeMount{Name: name, MountPath: dir})
+}
+
+// remoteAllowed checks the remote of the sink against the repository
+// allowlist and records the outcome in the RepoNotAllowed condition
+func (r *ExtractReconciler) remoteAllowed(ctx context.Context, m *primerv1alpha1.Extract) (bool, error) {
+	list, err := allowlist.Load(ctx, r.apiReader, r.Config)
+	if err != nil {
+		return false, err
+	}
+	if list == nil && m.Status.Conditions.GetCondition(primerv1alpha1.ConditionRepoNotAllowed) == nil {
+		return true, nil
+	}
+	remote := m.Spec.Remote()
+	allowed := remote == "" || list.Allows(remote)
+	condition := status.Condition{
+		Type:    primerv1alpha1.ConditionRepoNotAllowed,
+		Status:  corev1.ConditionFalse,
+		Reason:  primerv1alpha1.RepoAllowedReason,
+		Message: "The remote is allowed",
+	}
+	if !allowed {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = primerv1alpha1.RepoNotAllowedReason
+		condition.Message = remote + " is not in the repository allowlist"
+	}
+	if m.Status.Conditions == nil {
+		m.Status.Conditions = status.Conditions{}
+	}
+	if m.Status.Conditions.SetCondition(condition) {
+		if err := r.Status().Update(ctx, m); err != nil {
+			return false, err
+		}
+	}
+	return allowed, nil
+}
+
+// setReconciledError records why the Extract does not run in the Reconciled
+// condition
+func (r *ExtractReconciler) setReconciledError(ctx context.Context, m *primerv1alpha1.Extract, err error) error {
+	if m.Status.Conditions == nil {
+		m.Status.Conditions = status.Conditions{}
+	}
+	m.Status.Conditions.SetCondition(status.Condition{
+		Type:    primerv1alpha1.ConditionReconciled,
+		Status:  corev1.ConditionFalse,
+		Reason:  primerv1alpha1.ReconciledReasonError,
+		Message: err.Error(),
+	})
+	return r.Status().Update(ctx, m)
+}
+
+// ensureOutput creates the PersistentVolumeClaim or ConfigMap the in-cluster
+// sinks write to. They are read through the API reader so that the manager
+// does not cache every ConfigMap of the cluster.
+func (r *ExtractReconciler) ensureOutput(ctx context.Context, m *primerv1alpha1.Extract) error {
+	output := outputForExtract(m)
+	if output == nil {
+		return nil
+	}
+	key := types.NamespacedName{Name: output.GetName(), Namespace: output.GetNamespace()}
+	err := r.apiReader.Get(ctx, key, output.DeepCopyObject().(client.Object))
+	if err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+	ctrl.SetControllerReference(m, output, r.Scheme)
+	err = r.Create(ctx, output)
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// outputForExtract returns the object the in-cluster sink of the Extract
+// writes to, or nil for the other sinks
+func outputForExtract(m *primerv1alpha1.Extract) client.Object {
+	if m.Spec.Sink == nil {
+		return nil
+	}
+	meta := metav1.ObjectMeta{Name: primerv1alpha1.OutputName(m.Name), Namespace: m.Namespace}
+	switch m.Spec.Sink.Type {
+	case primerv1alpha1.SinkConfigMap:
+		return &corev1.ConfigMap{ObjectMeta: meta}
+	case primerv1alpha1.SinkPVC:
+		size := resource.MustParse("1Gi")
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: meta,
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			},
+		}
+		if m.Spec.Sink.PVC != nil {
+			pvc.Spec.StorageClassName = m.Spec.Sink.PVC.StorageClassName
+			if m.Spec.Sink.PVC.Size != nil {
+				size = *m.Spec.Sink.PVC.Size
+			}
+		}
+		pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: size}
+		return pvc
+	}
+	return nil
+}
+
 func (r *ExtractReconciler) saGenerate(m *primerv1alpha1.Extract) *corev1.ServiceAccount {
 	// Define a new Service Account object
 	serviceAcct := &corev1.ServiceAccount{
@@ -271,25 +657,123 @@ func (r *ExtractReconciler) saGenerate(m *primerv1alpha1.Extract) *corev1.Servic
 	return serviceAcct
 }
 
-func (r *ExtractReconciler) roleGenerate(m *primerv1alpha1.Extract) *rbacv1.Role {
+func (r *ExtractReconciler) roleGenerate(m *primerv1alpha1.Extract, rules []rbacv1.PolicyRule) *rbacv1.Role {
 	role := &rbacv1.Role{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "primer-extract-" + m.Name,
 			Namespace: m.Namespace,
 		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				APIGroups: []string{"*"},
-				Resources: []string{"*"},
-				Verbs:     []string{"get", "list"},
-			},
-		},
+		Rules: rules,
+	}
+	if m.Spec.Sink != nil && m.Spec.Sink.Type == primerv1alpha1.SinkConfigMap {
+		// The configmap sink replaces the tarball held by its ConfigMap
+		role.Rules = append(role.Rules, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{primerv1alpha1.OutputName(m.Name)},
+			Verbs:         []string{"get", "update"},
+		})
 	}
 	// Service reconcile finished
 	ctrl.SetControllerReference(m, role, r.Scheme)
 	return role
 }
 
+// errNoRequester is returned for Extracts lacking the requester recorded by
+// the webhook, such as Extracts created while it was not running
+var errNoRequester = fmt.Errorf("the Extract has no requester recorded by the webhook, update it to record one")
+
+// requesterRules returns the rules of the extraction Role. Every namespaced
+// resource is checked with a SubjectAccessReview for the requester recorded
+// by the webhook, the Role only grants the resources the requester can list
+// and the others are returned as excluded. Extracts without a requester are
+// refused with errNoRequester. Extractions of other clusters authenticate
+// with their kubeconfig and get no rules, but the requester must be able to
+// read the kubeconfig Secret.
+func (r *ExtractReconciler) requesterRules(ctx context.Context, m *primerv1alpha1.Extract) ([]rbacv1.PolicyRule, []string, error) {
+	verbs := []string{"get", "list"}
+	user, groups, ok := primerv1alpha1.Requester(m)
+	if !ok {
+		return nil, nil, errNoRequester
+	}
+	if m.Spec.Source != nil {
+		name := m.Spec.Source.KubeconfigSecretRef.Name
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   user,
+				Groups: groups,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: m.Namespace,
+					Verb:      "get",
+					Resource:  "secrets",
+					Name:      name,
+				},
+			},
+		}
+		if err := r.Create(ctx, review); err != nil {
+			return nil, nil, err
+		}
+		if !review.Status.Allowed {
+			return nil, nil, fmt.Errorf("%s cannot get the kubeconfig Secret %s", user, name)
+		}
+		return nil, nil, nil
+	}
+	lists, err := r.discovery.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, nil, err
+	}
+	allowed := map[string][]string{}
+	excluded := []string{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !sets.NewString(resource.Verbs...).Has("list") {
+				continue
+			}
+			review := &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					User:   user,
+					Groups: groups,
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: m.Namespace,
+						Verb:      "list",
+						Group:     gv.Group,
+						Resource:  resource.Name,
+					},
+				},
+			}
+			if err := r.Create(ctx, review); err != nil {
+				return nil, nil, err
+			}
+			if review.Status.Allowed {
+				allowed[gv.Group] = append(allowed[gv.Group], resource.Name)
+			} else {
+				excluded = append(excluded, schema.GroupResource{Group: gv.Group, Resource: resource.Name}.String())
+			}
+		}
+	}
+
+	groupNames := make([]string, 0, len(allowed))
+	for group := range allowed {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+	rules := []rbacv1.PolicyRule{}
+	for _, group := range groupNames {
+		resources := allowed[group]
+		sort.Strings(resources)
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{group}, Resources: resources, Verbs: verbs})
+	}
+	sort.Strings(excluded)
+	if len(excluded) == 0 {
+		excluded = nil
+	}
+	return rules, excluded, nil
+}
+
 func (r *ExtractReconciler) roleBindingGenerate(m *primerv1alpha1.Extract) *rbacv1.RoleBinding {
 	roleBinding := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
@@ -319,10 +803,61 @@ func isJobComplete(job *batchv1.Job) bool {
 	return jobComplete
 }
 
+// jobResult returns the result the extraction reported as termination
+// message of its last finished pod, or nil if there is none. Failed pods
+// report the error instead.
+func (r *ExtractReconciler) jobResult(ctx context.Context, job *batchv1.Job) (*sink.Result, error) {
+	pods := &corev1.PodList{}
+	if err := r.apiReader.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return nil, err
+	}
+	var last *corev1.Pod
+	for i, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+		if last == nil || last.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			last = &pods.Items[i]
+		}
+	}
+	if last == nil {
+		return nil, nil
+	}
+	for _, container := range last.Status.ContainerStatuses {
+		if container.State.Terminated == nil || container.State.Terminated.Message == "" {
+			continue
+		}
+		result := &sink.Result{}
+		if err := json.Unmarshal([]byte(container.State.Terminated.Message), result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	return nil, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ExtractReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	dynamicClient, err := dynamic.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	r.changes = newChangeWatcher(ctrl.Log.WithName("changes"), dynamicClient, mgr.GetRESTMapper())
+	r.queue = newTargetQueue()
+	r.apiReader = mgr.GetAPIReader()
+	r.discovery, err = discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	r.clientset, err = kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&primerv1alpha1.Extract{}).
+		Watches(&source.Channel{Source: r.changes.events}, &handler.EnqueueRequestForObject{}).
+		Watches(&source.Channel{Source: r.queue.events}, &handler.EnqueueRequestForObject{}).
 		Owns(&batchv1.Job{}).
 		Owns(&rbacv1.Role{}).
 		Owns(&rbacv1.RoleBinding{}).
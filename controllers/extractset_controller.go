@@ -0,0 +1,279 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/operator-framework/operator-lib/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// ExtractSetReconciler reconciles a ExtractSet object
+type ExtractSetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=primer.gitops.io,resources=extractsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=primer.gitops.io,resources=extractsets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=primer.gitops.io,resources=extractsets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// Reconcile creates an Extract in every namespace selected by the ExtractSet,
+// keeps their specs in line with the template, deletes the Extracts of
+// namespaces that are no longer selected and aggregates their status.
+func (r *ExtractSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	// Fetch the ExtractSet instance
+	instance := &primerv1alpha1.ExtractSet{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Owned Extracts are garbage collected through their owner reference
+			log.Info("ExtractSet resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ExtractSet")
+		return ctrl.Result{}, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&instance.Spec.NamespaceSelector)
+	if err != nil {
+		log.Error(err, "Invalid namespace selector")
+		return ctrl.Result{}, r.setExtractSetCondition(ctx, instance, err)
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		log.Error(err, "Failed to list Namespaces")
+		return ctrl.Result{}, err
+	}
+
+	children := &primerv1alpha1.ExtractList{}
+	if err := r.List(ctx, children, client.MatchingLabels{primerv1alpha1.ExtractSetLabel: instance.Name}); err != nil {
+		log.Error(err, "Failed to list Extracts")
+		return ctrl.Result{}, err
+	}
+	// Only Extracts controlled by the set are updated or deleted, the label
+	// alone could be set by anyone
+	existing := map[string]*primerv1alpha1.Extract{}
+	for i := range children.Items {
+		if metav1.IsControlledBy(&children.Items[i], instance) {
+			existing[children.Items[i].Namespace] = &children.Items[i]
+		}
+	}
+
+	selected := []string{}
+	conflicts := []string{}
+	var owned, completed int32
+	for _, ns := range namespaces.Items {
+		if ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		selected = append(selected, ns.Name)
+
+		spec, err := renderExtractSpec(instance.Spec.Template, templateVars{Namespace: ns.Name, Name: instance.Name})
+		if err != nil {
+			log.Error(err, "Failed to render Extract template", "Namespace", ns.Name)
+			return ctrl.Result{}, r.setExtractSetCondition(ctx, instance, err)
+		}
+
+		found, ok := existing[ns.Name]
+		delete(existing, ns.Name)
+		if !ok {
+			extract := r.extractForExtractSet(instance, ns.Name, spec)
+			log.Info("Creating a new Extract", "Extract.Namespace", extract.Namespace, "Extract.Name", extract.Name)
+			err := r.Create(ctx, extract)
+			if errors.IsAlreadyExists(err) {
+				// An Extract of the same name the set does not control
+				log.Info("Extract exists without being owned by the ExtractSet", "Extract.Namespace", extract.Namespace, "Extract.Name", extract.Name)
+				conflicts = append(conflicts, ns.Name)
+				continue
+			} else if err != nil {
+				log.Error(err, "Failed to create new Extract", "Extract.Namespace", extract.Namespace, "Extract.Name", extract.Name)
+				return ctrl.Result{}, err
+			}
+			owned++
+			continue
+		}
+
+		owned++
+		// A changed set runs with the permissions of whoever changed it
+		requesterChanged := copyRequester(instance, found)
+		if requesterChanged || !reflect.DeepEqual(found.Spec, spec) {
+			found.Spec = spec
+			log.Info("Updating Extract", "Extract.Namespace", found.Namespace, "Extract.Name", found.Name)
+			if err := r.Update(ctx, found); err != nil {
+				log.Error(err, "Failed to update Extract", "Extract.Namespace", found.Namespace, "Extract.Name", found.Name)
+				return ctrl.Result{}, err
+			}
+		}
+		if found.Status.Completed {
+			completed++
+		}
+	}
+
+	// Whatever is left belongs to namespaces that are no longer selected
+	for _, extract := range existing {
+		log.Info("Deleting Extract", "Extract.Namespace", extract.Namespace, "Extract.Name", extract.Name)
+		if err := r.Delete(ctx, extract); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete Extract", "Extract.Namespace", extract.Namespace, "Extract.Name", extract.Name)
+			return ctrl.Result{}, err
+		}
+	}
+
+	sort.Strings(selected)
+	sort.Strings(conflicts)
+	instance.Status.Namespaces = selected
+	instance.Status.Extracts = owned
+	instance.Status.Completed = completed
+	instance.Status.Conflicts = conflicts
+	if len(conflicts) > 0 {
+		err := fmt.Errorf("Extracts named %s not owned by the ExtractSet exist in: %s", instance.Name, strings.Join(conflicts, ", "))
+		return ctrl.Result{}, r.setExtractSetCondition(ctx, instance, err)
+	}
+	return ctrl.Result{}, r.setExtractSetCondition(ctx, instance, nil)
+}
+
+// setExtractSetCondition records the outcome of a reconcile in the status
+func (r *ExtractSetReconciler) setExtractSetCondition(ctx context.Context, instance *primerv1alpha1.ExtractSet, err error) error {
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = status.Conditions{}
+	}
+	if err == nil {
+		instance.Status.Conditions.SetCondition(
+			status.Condition{
+				Type:    primerv1alpha1.ConditionReconciled,
+				Status:  corev1.ConditionTrue,
+				Reason:  primerv1alpha1.ReconciledReasonComplete,
+				Message: "Reconcile complete",
+			})
+	} else {
+		instance.Status.Conditions.SetCondition(
+			status.Condition{
+				Type:    primerv1alpha1.ConditionReconciled,
+				Status:  corev1.ConditionFalse,
+				Reason:  primerv1alpha1.ReconciledReasonError,
+				Message: err.Error(),
+			})
+	}
+	return r.Status().Update(ctx, instance)
+}
+
+// extractForExtractSet returns the Extract an ExtractSet owns in a namespace
+func (r *ExtractSetReconciler) extractForExtractSet(m *primerv1alpha1.ExtractSet, namespace string, spec primerv1alpha1.ExtractSpec) *primerv1alpha1.Extract {
+	extract := &primerv1alpha1.Extract{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{primerv1alpha1.ExtractSetLabel: m.Name},
+		},
+		Spec: spec,
+	}
+	// The Extracts run with the permissions of whoever created the ExtractSet
+	copyRequester(m, extract)
+	ctrl.SetControllerReference(m, extract, r.Scheme)
+	return extract
+}
+
+// copyRequester copies the requester annotations of an ExtractSet to one of
+// its Extracts and reports whether they changed
+func copyRequester(m *primerv1alpha1.ExtractSet, extract *primerv1alpha1.Extract) bool {
+	changed := false
+	for _, key := range []string{primerv1alpha1.RequesterAnnotation, primerv1alpha1.RequesterGroupsAnnotation} {
+		if value, ok := m.Annotations[key]; ok && extract.Annotations[key] != value {
+			metav1.SetMetaDataAnnotation(&extract.ObjectMeta, key, value)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// templateVars are the values available to Extract templates. Name and
+// ClusterName serve the path, which the extraction expands as well.
+type templateVars struct {
+	Namespace   string
+	Name        string
+	ClusterName string
+}
+
+// renderExtractSpec expands the templated fields of an Extract spec
+func renderExtractSpec(spec primerv1alpha1.ExtractSpec, vars templateVars) (primerv1alpha1.ExtractSpec, error) {
+	out := spec
+	vars.ClusterName = spec.ClusterName()
+	for _, field := range []*string{&out.Repo, &out.Branch, &out.Path} {
+		tmpl, err := template.New("extract").Option("missingkey=error").Parse(*field)
+		if err != nil {
+			return out, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, vars); err != nil {
+			return out, err
+		}
+		*field = buf.String()
+	}
+	return out, nil
+}
+
+// extractSetForExtract requeues the ExtractSet named like an Extract, which
+// also catches the removal of conflicting Extracts the set does not own
+func (r *ExtractSetReconciler) extractSetForExtract(obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.GetName()}}}
+}
+
+// extractSetsForNamespace requeues every ExtractSet when a namespace changes
+// so that label changes add or remove the namespace's Extract
+func (r *ExtractSetReconciler) extractSetsForNamespace(obj client.Object) []reconcile.Request {
+	sets := &primerv1alpha1.ExtractSetList{}
+	if err := r.List(context.Background(), sets); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(sets.Items))
+	for _, set := range sets.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: set.Name}})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ExtractSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&primerv1alpha1.ExtractSet{}).
+		Watches(&source.Kind{Type: &primerv1alpha1.Extract{}}, handler.EnqueueRequestsFromMapFunc(r.extractSetForExtract)).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.extractSetsForNamespace)).
+		Complete(r)
+}
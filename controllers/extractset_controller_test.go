@@ -0,0 +1,157 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("renderExtractSpec", func() {
+	It("expands the repo, branch and path", func() {
+		spec, err := renderExtractSpec(primerv1alpha1.ExtractSpec{
+			Repo:    "git@example.com:org/{{ .Namespace }}.git",
+			Branch:  "env-{{ .Namespace }}",
+			Path:    "namespaces/{{ .Namespace }}/{{ .Name }}",
+			Tagging: &primerv1alpha1.TaggingSpec{Name: "{{ .Namespace }}-{{ .Run }}"},
+		}, templateVars{Namespace: "shop", Name: "primer"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Repo).To(Equal("git@example.com:org/shop.git"))
+		Expect(spec.Branch).To(Equal("env-shop"))
+		Expect(spec.Path).To(Equal("namespaces/shop/primer"))
+		// Tags are named when the extraction pushes
+		Expect(spec.Tagging.Name).To(Equal("{{ .Namespace }}-{{ .Run }}"))
+	})
+
+	It("fails on unknown variables", func() {
+		_, err := renderExtractSpec(primerv1alpha1.ExtractSpec{Branch: "{{ .Cluster }}"}, templateVars{Namespace: "shop"})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ExtractSetReconciler", func() {
+	ctx := context.Background()
+	var reconciler *ExtractSetReconciler
+
+	setNamespaceLabel := func(name, value string) {
+		ns := &corev1.Namespace{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, ns)
+		if err != nil {
+			ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": value}}}
+			Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+			return
+		}
+		ns.Labels = map[string]string{"team": value}
+		Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+	}
+	reconcileSet := func(name string) *primerv1alpha1.ExtractSet {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+		set := &primerv1alpha1.ExtractSet{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name}, set)).To(Succeed())
+		return set
+	}
+	extractIn := func(namespace, name string) (*primerv1alpha1.Extract, error) {
+		extract := &primerv1alpha1.Extract{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, extract)
+		return extract, err
+	}
+
+	BeforeEach(func() {
+		reconciler = &ExtractSetReconciler{Client: k8sClient, Scheme: scheme.Scheme}
+	})
+
+	It("fans out Extracts and deletes those of deselected namespaces", func() {
+		setNamespaceLabel("fanout-a", "fanout")
+		setNamespaceLabel("fanout-b", "fanout")
+		set := &primerv1alpha1.ExtractSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "fanout"},
+			Spec: primerv1alpha1.ExtractSetSpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "fanout"}},
+				Template: primerv1alpha1.ExtractSpec{
+					Repo:   "git@example.com:org/apps.git",
+					Branch: "main",
+					Path:   "namespaces/{{ .Namespace }}",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, set)).To(Succeed())
+
+		set = reconcileSet("fanout")
+		Expect(set.Status.Namespaces).To(Equal([]string{"fanout-a", "fanout-b"}))
+		Expect(set.Status.Extracts).To(Equal(int32(2)))
+		extract, err := extractIn("fanout-b", "fanout")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(extract.Spec.Path).To(Equal("namespaces/fanout-b"))
+		Expect(metav1.IsControlledBy(extract, set)).To(BeTrue())
+
+		setNamespaceLabel("fanout-b", "other")
+		set = reconcileSet("fanout")
+		Expect(set.Status.Namespaces).To(Equal([]string{"fanout-a"}))
+		Expect(set.Status.Extracts).To(Equal(int32(1)))
+		_, err = extractIn("fanout-b", "fanout")
+		Expect(client.IgnoreNotFound(err)).To(Succeed())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reports Extracts of the same name it does not own", func() {
+		setNamespaceLabel("conflict-a", "conflict")
+		// A hand-made Extract, even with the label of the set, is left alone
+		manual := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "conflict",
+				Namespace: "conflict-a",
+				Labels:    map[string]string{primerv1alpha1.ExtractSetLabel: "conflict"},
+			},
+			Spec: primerv1alpha1.ExtractSpec{Repo: "git@example.com:org/manual.git", Branch: "main"},
+		}
+		Expect(k8sClient.Create(ctx, manual)).To(Succeed())
+		set := &primerv1alpha1.ExtractSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "conflict"},
+			Spec: primerv1alpha1.ExtractSetSpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "conflict"}},
+				Template:          primerv1alpha1.ExtractSpec{Repo: "git@example.com:org/apps.git", Branch: "main"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, set)).To(Succeed())
+
+		set = reconcileSet("conflict")
+		Expect(set.Status.Extracts).To(BeZero())
+		Expect(set.Status.Conflicts).To(Equal([]string{"conflict-a"}))
+		Expect(set.Status.Conditions.IsFalseFor(primerv1alpha1.ConditionReconciled)).To(BeTrue())
+		extract, err := extractIn("conflict-a", "conflict")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(extract.Spec.Repo).To(Equal("git@example.com:org/manual.git"))
+
+		// Deselecting the namespace does not delete the hand-made Extract
+		setNamespaceLabel("conflict-a", "other")
+		set = reconcileSet("conflict")
+		Expect(set.Status.Conflicts).To(BeEmpty())
+		_, err = extractIn("conflict-a", "conflict")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
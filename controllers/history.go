@@ -0,0 +1,128 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const (
+	// historyLimit is the number of extractions kept in the status
+	historyLimit = 10
+	// logTailLines is the number of lines kept by retainLogs
+	logTailLines = int64(500)
+)
+
+// recordRun adds the finished extraction of job to the history of the Extract
+// and updates the digest, revision and object count of the last extraction
+func (r *ExtractReconciler) recordRun(ctx context.Context, m *primerv1alpha1.Extract, job *batchv1.Job) error {
+	run := primerv1alpha1.ExtractRun{
+		StartTime:      m.Status.LastRunTime,
+		CompletionTime: metav1.Now(),
+		Result:         primerv1alpha1.RunSucceeded,
+	}
+	result, resultErr := r.jobResult(ctx, job)
+	if isJobFailed(job) {
+		run.Result = primerv1alpha1.RunFailed
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed {
+				run.Message = condition.Message
+			}
+		}
+		// The extraction reports why storing failed
+		if result != nil {
+			run.PushRetries = int32(result.PushRetries)
+			run.Tag = result.Tag
+			if result.Error != "" {
+				run.Message = result.Error
+			}
+		}
+	} else if result != nil {
+		run.PushRetries = int32(result.PushRetries)
+		run.Location = result.Location
+		run.Changed = result.Changed
+		run.Revision = result.Revision
+		run.Objects = int32(result.Objects)
+		run.CreatedBranch = result.CreatedBranch
+		run.Tag = result.Tag
+		// Failures after storing, such as tagging, keep the run succeeded
+		run.Message = result.Error
+		if result.Digest != "" {
+			m.Status.Digest = result.Digest
+		}
+		m.Status.Revision = result.Revision
+		if result.CreatedBranch {
+			m.Status.BranchCreated = true
+		}
+		m.Status.Objects = run.Objects
+	}
+
+	m.Status.History = append([]primerv1alpha1.ExtractRun{run}, m.Status.History...)
+	if len(m.Status.History) > historyLimit {
+		m.Status.History = m.Status.History[:historyLimit]
+	}
+	return resultErr
+}
+
+// retainLogs copies the tail of the log of the extraction pod of job to the
+// logs ConfigMap of the Extract, which outlives the pod
+func (r *ExtractReconciler) retainLogs(ctx context.Context, m *primerv1alpha1.Extract, job *batchv1.Job) error {
+	pods := &corev1.PodList{}
+	if err := r.apiReader.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return nil
+	}
+	// The last pod holds the last attempt
+	pod := pods.Items[0]
+	for _, p := range pods.Items[1:] {
+		if pod.CreationTimestamp.Before(&p.CreationTimestamp) {
+			pod = p
+		}
+	}
+	tail := logTailLines
+	data, err := r.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{TailLines: &tail}).DoRaw(ctx)
+	if err != nil {
+		return err
+	}
+
+	logs := &corev1.ConfigMap{}
+	err = r.apiReader.Get(ctx, types.NamespacedName{Name: primerv1alpha1.LogsName(m.Name), Namespace: m.Namespace}, logs)
+	if errors.IsNotFound(err) {
+		logs = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: primerv1alpha1.LogsName(m.Name), Namespace: m.Namespace},
+			Data:       map[string]string{primerv1alpha1.LogsKey: string(data)},
+		}
+		ctrl.SetControllerReference(m, logs, r.Scheme)
+		return r.Create(ctx, logs)
+	} else if err != nil {
+		return err
+	}
+	logs.Data = map[string]string{primerv1alpha1.LogsKey: string(data)}
+	return r.Update(ctx, logs)
+}
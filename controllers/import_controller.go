@@ -0,0 +1,384 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/operator-framework/operator-lib/status"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// ImportReconciler reconciles a Import object
+type ImportReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=primer.gitops.io,resources=imports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=primer.gitops.io,resources=imports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=primer.gitops.io,resources=imports/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=admin
+
+// Reconcile runs a Job applying the objects of the repository with primer
+// import. The Job records the result of every object in the status of the
+// Import, the Import is completed once the Job has finished.
+func (r *ImportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	// Fetch the Import instance
+	instance := &primerv1alpha1.Import{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("Import resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get Import")
+		return ctrl.Result{}, err
+	}
+	if instance.Status.Completed {
+		if instance.Generation == instance.Status.ObservedGeneration &&
+			instance.Annotations[primerv1alpha1.RunAnnotation] == instance.Status.RunRequest {
+			return ctrl.Result{}, nil
+		}
+		// Run again when the spec or the run annotation changed
+		log.Info("Import changed or run requested, starting a new import")
+		instance.Status.Completed = false
+		if err := r.Status().Update(ctx, instance); err != nil {
+			log.Error(err, "Failed to update Import status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// The manager creates and binds the target namespace on behalf of the
+	// requester, which must be allowed to do so itself
+	if err := r.authorizeRequester(ctx, instance); err != nil {
+		log.Error(err, "The requester may not run the Import")
+		if instance.Status.Conditions == nil {
+			instance.Status.Conditions = status.Conditions{}
+		}
+		instance.Status.Conditions.SetCondition(status.Condition{
+			Type:    primerv1alpha1.ConditionReconciled,
+			Status:  corev1.ConditionFalse,
+			Reason:  primerv1alpha1.ReconciledReasonError,
+			Message: err.Error(),
+		})
+		if err := r.Status().Update(ctx, instance); err != nil {
+			log.Error(err, "Failed to update Import status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	// The target namespace is created up front, the Namespace object of the
+	// repository only adds its labels and annotations
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: importTarget(instance)}}
+	if err := r.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create the target Namespace", "Namespace", ns.Name)
+		return ctrl.Result{}, err
+	}
+
+	for _, obj := range r.accessForImport(instance) {
+		found := obj.DeepCopyObject().(client.Object)
+		err := r.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, found)
+		if err == nil {
+			continue
+		} else if !errors.IsNotFound(err) {
+			log.Error(err, "Failed to get Import access", "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+			return ctrl.Result{}, err
+		}
+		log.Info("Creating Import access", "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+		if err := r.Create(ctx, obj); err != nil {
+			log.Error(err, "Failed to create Import access", "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Check if the Job already exists, if not create a new one
+	found := &batchv1.Job{}
+	err = r.Get(ctx, types.NamespacedName{Name: "primer-import-" + instance.Name, Namespace: instance.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		job := r.jobForImport(instance)
+		log.Info("Creating a new Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		if err := r.Create(ctx, job); err != nil {
+			log.Error(err, "Failed to create new Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+			return ctrl.Result{}, err
+		}
+		now := metav1.Now()
+		instance.Status.LastRunTime = &now
+		instance.Status.ObservedGeneration = instance.Generation
+		instance.Status.RunRequest = instance.Annotations[primerv1alpha1.RunAnnotation]
+		if err := r.Status().Update(ctx, instance); err != nil {
+			log.Error(err, "Failed to update Import status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Job")
+		return ctrl.Result{}, err
+	}
+
+	failed := isJobFailed(found)
+	if !isJobComplete(found) && !failed {
+		// The Job is still running, its completion triggers the next reconcile
+		return ctrl.Result{}, nil
+	}
+
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = status.Conditions{}
+	}
+	instance.Status.Completed = true
+	condition := status.Condition{
+		Type:    primerv1alpha1.ConditionReconciled,
+		Status:  corev1.ConditionTrue,
+		Reason:  primerv1alpha1.ReconciledReasonComplete,
+		Message: fmt.Sprintf("Imported %d objects", len(instance.Status.Resources)),
+	}
+	if failed {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = primerv1alpha1.ReconciledReasonError
+		condition.Message = "The import failed, see status.resources and the logs of the Job"
+	}
+	instance.Status.Conditions.SetCondition(condition)
+	if err := r.Status().Update(ctx, instance); err != nil {
+		log.Error(err, "Failed to update Import status")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Cleaning up Primer Resources")
+	r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	for _, obj := range r.accessForImport(instance) {
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete Import access", "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// importTarget returns the namespace the Import applies namespaced objects to
+func importTarget(m *primerv1alpha1.Import) string {
+	if m.Spec.TargetNamespace != "" {
+		return m.Spec.TargetNamespace
+	}
+	return m.Namespace
+}
+
+// authorizeRequester checks that the requester of an Import could do what
+// the manager does on its behalf: create the target namespace if it is
+// missing and either bind the admin role in it or run the Job as the given
+// service account. Imports without a requester recorded by the webhook never
+// run.
+func (r *ImportReconciler) authorizeRequester(ctx context.Context, m *primerv1alpha1.Import) error {
+	target := importTarget(m)
+	user, groups, ok := primerv1alpha1.Requester(m)
+	if !ok {
+		return fmt.Errorf("imports require a requester recorded by the webhook")
+	}
+	checks := []authorizationv1.ResourceAttributes{}
+	err := r.Get(ctx, types.NamespacedName{Name: target}, &corev1.Namespace{})
+	if errors.IsNotFound(err) {
+		checks = append(checks, authorizationv1.ResourceAttributes{Verb: "create", Resource: "namespaces"})
+	} else if err != nil {
+		return err
+	}
+	if m.Spec.ServiceAccountName == "" {
+		checks = append(checks, authorizationv1.ResourceAttributes{
+			Namespace: target,
+			Verb:      "bind",
+			Group:     rbacv1.GroupName,
+			Resource:  "clusterroles",
+			Name:      "admin",
+		})
+	} else {
+		checks = append(checks, authorizationv1.ResourceAttributes{
+			Namespace: m.Namespace,
+			Verb:      "impersonate",
+			Resource:  "serviceaccounts",
+			Name:      m.Spec.ServiceAccountName,
+		})
+	}
+	for i := range checks {
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:               user,
+				Groups:             groups,
+				ResourceAttributes: &checks[i],
+			},
+		}
+		if err := r.Create(ctx, review); err != nil {
+			return err
+		}
+		if !review.Status.Allowed {
+			what := checks[i].Resource
+			if checks[i].Name != "" {
+				what += "/" + checks[i].Name
+			}
+			if checks[i].Namespace == "" {
+				return fmt.Errorf("%s cannot %s %s for the missing target namespace %s", user, checks[i].Verb, what, target)
+			}
+			return fmt.Errorf("%s cannot %s %s in the namespace %s", user, checks[i].Verb, what, checks[i].Namespace)
+		}
+	}
+	return nil
+}
+
+// importServiceAccount returns the service account the Job runs as
+func importServiceAccount(m *primerv1alpha1.Import) string {
+	if m.Spec.ServiceAccountName != "" {
+		return m.Spec.ServiceAccountName
+	}
+	return "primer-import-" + m.Name
+}
+
+// accessForImport returns the objects granting the Job access to the Import
+// and, unless a service account is given, the admin role of the target
+// namespace. The admin RoleBinding may live in another namespace, so it is
+// not owned by the Import and is removed once the Job has finished.
+func (r *ImportReconciler) accessForImport(m *primerv1alpha1.Import) []client.Object {
+	name := "primer-import-" + m.Name
+	meta := func() metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: m.Namespace}
+	}
+	subjects := []rbacv1.Subject{{Kind: "ServiceAccount", Name: importServiceAccount(m), Namespace: m.Namespace}}
+	objs := []client.Object{
+		&rbacv1.Role{
+			ObjectMeta: meta(),
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups:     []string{primerv1alpha1.GroupVersion.Group},
+					Resources:     []string{"imports"},
+					ResourceNames: []string{m.Name},
+					Verbs:         []string{"get"},
+				},
+				{
+					APIGroups:     []string{primerv1alpha1.GroupVersion.Group},
+					Resources:     []string{"imports/status"},
+					ResourceNames: []string{m.Name},
+					Verbs:         []string{"update"},
+				},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: meta(),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+			Subjects:   subjects,
+		},
+	}
+	if m.Spec.ServiceAccountName == "" {
+		objs = append(objs, &corev1.ServiceAccount{ObjectMeta: meta()})
+	}
+	for _, obj := range objs {
+		ctrl.SetControllerReference(m, obj, r.Scheme)
+	}
+	if m.Spec.ServiceAccountName == "" {
+		objs = append(objs, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name + "-admin",
+				Namespace: importTarget(m),
+				Labels:    map[string]string{primerv1alpha1.ImportLabel: m.Name},
+			},
+			RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+			Subjects: subjects,
+		})
+	}
+	return objs
+}
+
+// jobForImport returns the Job running primer import
+func (r *ImportReconciler) jobForImport(m *primerv1alpha1.Import) *batchv1.Job {
+	mode := int32(0600)
+	// A second attempt picks up the kinds of CRDs established in the meantime
+	backoffLimit := int32(1)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "primer-import-" + m.Name,
+			Namespace: m.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:      "Never",
+					ServiceAccountName: importServiceAccount(m),
+					Containers: []corev1.Container{{
+						Image:   "quay.io/octo-emerging/gitops-primer-extract:latest",
+						Name:    m.Name,
+						Command: []string{"/bin/sh", "-c", "/importer.sh"},
+						Env: []corev1.EnvVar{
+							{Name: "IMPORT_NAME", Value: m.Name},
+							{Name: "NAMESPACE", Value: m.Namespace},
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "repo", MountPath: "/repo"},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "repo", VolumeSource: corev1.VolumeSource{
+							EmptyDir: &corev1.EmptyDirVolumeSource{},
+						}},
+					},
+				},
+			},
+		},
+	}
+	if m.Spec.Secret != "" {
+		pod := &job.Spec.Template.Spec
+		pod.Volumes = append(pod.Volumes, corev1.Volume{Name: "sshkeys", VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  m.Spec.Secret,
+				DefaultMode: &mode,
+			}},
+		})
+		pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "sshkeys", MountPath: "/keys"})
+		pod.Containers[0].Env = append(pod.Containers[0].Env, sshEnv(m.Spec.Repo)...)
+	}
+	ctrl.SetControllerReference(m, job, r.Scheme)
+	return job
+}
+
+func isJobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&primerv1alpha1.Import{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// reviewClient answers SubjectAccessReviews with allow and records them
+type reviewClient struct {
+	client.Client
+	allow   func(*authorizationv1.ResourceAttributes) bool
+	reviews []authorizationv1.ResourceAttributes
+}
+
+func (c *reviewClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	review, ok := obj.(*authorizationv1.SubjectAccessReview)
+	if !ok {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	c.reviews = append(c.reviews, *review.Spec.ResourceAttributes)
+	review.Status.Allowed = c.allow(review.Spec.ResourceAttributes)
+	return nil
+}
+
+var _ = Describe("ImportReconciler", func() {
+	ctx := context.Background()
+	var reviews *reviewClient
+	var reconciler *ImportReconciler
+
+	BeforeEach(func() {
+		reviews = &reviewClient{Client: k8sClient, allow: func(*authorizationv1.ResourceAttributes) bool { return true }}
+		reconciler = &ImportReconciler{Client: reviews, Scheme: scheme.Scheme}
+	})
+
+	createImport := func(namespace string, spec primerv1alpha1.ImportSpec, annotations map[string]string) *primerv1alpha1.Import {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		if err := k8sClient.Create(ctx, ns); err != nil {
+			Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+		}
+		imp := &primerv1alpha1.Import{
+			ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: namespace, Annotations: annotations},
+			Spec:       spec,
+		}
+		Expect(k8sClient.Create(ctx, imp)).To(Succeed())
+		return imp
+	}
+	requested := func() map[string]string {
+		return map[string]string{
+			primerv1alpha1.RequesterAnnotation:       "alice",
+			primerv1alpha1.RequesterGroupsAnnotation: `["tenants"]`,
+		}
+	}
+	reconcileImport := func(imp *primerv1alpha1.Import) error {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: imp.Name, Namespace: imp.Namespace}})
+		return err
+	}
+	getImport := func(imp *primerv1alpha1.Import) *primerv1alpha1.Import {
+		found := &primerv1alpha1.Import{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: imp.Name, Namespace: imp.Namespace}, found)).To(Succeed())
+		return found
+	}
+	getJob := func(imp *primerv1alpha1.Import) error {
+		return k8sClient.Get(ctx, types.NamespacedName{Name: "primer-import-" + imp.Name, Namespace: imp.Namespace}, &batchv1.Job{})
+	}
+
+	It("refuses Imports without a requester", func() {
+		imp := createImport("import-norequester", primerv1alpha1.ImportSpec{Repo: "git@example.com:org/repo.git", Path: "base"}, nil)
+		Expect(reconcileImport(imp)).NotTo(Succeed())
+		Expect(reviews.reviews).To(BeEmpty())
+		Expect(getImport(imp).Status.Conditions.GetCondition(primerv1alpha1.ConditionReconciled).Message).
+			To(ContainSubstring("require a requester"))
+		Expect(errors.IsNotFound(getJob(imp))).To(BeTrue())
+	})
+
+	It("checks that the requester may bind the admin role in its own namespace", func() {
+		reviews.allow = func(attributes *authorizationv1.ResourceAttributes) bool { return attributes.Verb != "bind" }
+		imp := createImport("import-own", primerv1alpha1.ImportSpec{Repo: "git@example.com:org/repo.git", Path: "base"}, requested())
+		Expect(reconcileImport(imp)).NotTo(Succeed())
+		Expect(reviews.reviews).To(ConsistOf(authorizationv1.ResourceAttributes{
+			Namespace: "import-own",
+			Verb:      "bind",
+			Group:     "rbac.authorization.k8s.io",
+			Resource:  "clusterroles",
+			Name:      "admin",
+		}))
+		Expect(getImport(imp).Status.Conditions.GetCondition(primerv1alpha1.ConditionReconciled).Message).
+			To(Equal("alice cannot bind clusterroles/admin in the namespace import-own"))
+		Expect(errors.IsNotFound(getJob(imp))).To(BeTrue())
+	})
+
+	It("checks that the requester may use the service account", func() {
+		reviews.allow = func(attributes *authorizationv1.ResourceAttributes) bool { return attributes.Verb != "impersonate" }
+		imp := createImport("import-sa", primerv1alpha1.ImportSpec{
+			Repo:               "git@example.com:org/repo.git",
+			Path:               "base",
+			TargetNamespace:    "import-sa-target",
+			ServiceAccountName: "cluster-importer",
+		}, requested())
+		Expect(reconcileImport(imp)).NotTo(Succeed())
+		Expect(reviews.reviews).To(ConsistOf(
+			authorizationv1.ResourceAttributes{Verb: "create", Resource: "namespaces"},
+			authorizationv1.ResourceAttributes{Namespace: "import-sa", Verb: "impersonate", Resource: "serviceaccounts", Name: "cluster-importer"},
+		))
+		Expect(getImport(imp).Status.Conditions.GetCondition(primerv1alpha1.ConditionReconciled).Message).
+			To(Equal("alice cannot impersonate serviceaccounts/cluster-importer in the namespace import-sa"))
+	})
+
+	It("runs a completed Import again when a run is requested", func() {
+		imp := createImport("import-rerun", primerv1alpha1.ImportSpec{Repo: "git@example.com:org/repo.git", Path: "base"}, requested())
+		Expect(reconcileImport(imp)).To(Succeed())
+		Expect(getJob(imp)).To(Succeed())
+		imp = getImport(imp)
+		Expect(imp.Status.ObservedGeneration).To(Equal(imp.Generation))
+
+		// The finished run is cleaned up and not repeated
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "primer-import-restore", Namespace: imp.Namespace}, job)).To(Succeed())
+		job.Status.Succeeded = 1
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+		Expect(reconcileImport(imp)).To(Succeed())
+		Expect(getImport(imp).Status.Completed).To(BeTrue())
+		Expect(errors.IsNotFound(getJob(imp))).To(BeTrue())
+		Expect(reconcileImport(imp)).To(Succeed())
+		Expect(errors.IsNotFound(getJob(imp))).To(BeTrue())
+
+		imp = getImport(imp)
+		imp.Annotations[primerv1alpha1.RunAnnotation] = "now"
+		Expect(k8sClient.Update(ctx, imp)).To(Succeed())
+		Expect(reconcileImport(imp)).To(Succeed())
+		Expect(getImport(imp).Status.Completed).To(BeFalse())
+		Expect(reconcileImport(imp)).To(Succeed())
+		Expect(getJob(imp)).To(Succeed())
+		Expect(getImport(imp).Status.RunRequest).To(Equal("now"))
+	})
+})
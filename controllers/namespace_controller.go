@@ -0,0 +1,253 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const (
+	// EnabledLabel enrolls a namespace when set to "true"
+	EnabledLabel = "primer.gitops.io/enabled"
+	// EnrolledLabel marks the Extracts created for enrolled namespaces
+	EnrolledLabel = "primer.gitops.io/enrolled"
+	// EnrolledExtractName is the name of the Extract created for an enrolled namespace
+	EnrolledExtractName = "primer"
+	// ExtractTemplateKey is the key of the config ConfigMap holding the Extract
+	// spec used for enrolled namespaces
+	ExtractTemplateKey = "extractTemplate"
+)
+
+// NamespaceReconciler creates an Extract in every namespace labelled with
+// primer.gitops.io/enabled=true
+type NamespaceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Config is the ConfigMap holding the Extract template
+	Config types.NamespacedName
+	// Requester and RequesterGroups are the identity enrolled Extracts run
+	// with. Namespaces are not enrolled without a Requester, the manager
+	// itself is never recorded.
+	Requester       string
+	RequesterGroups []string
+
+	// configReader reads the config ConfigMap, from a cache only holding
+	// objects of the config namespace so that the ConfigMap watch does not
+	// cache every ConfigMap in the cluster
+	configReader client.Reader
+}
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+
+// Reconcile creates or updates the Extract of an enrolled namespace and
+// deletes it once the namespace is no longer enrolled.
+func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	ns := &corev1.Namespace{}
+	err := r.Get(ctx, req.NamespacedName, ns)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get Namespace")
+		return ctrl.Result{}, err
+	}
+
+	found := &primerv1alpha1.Extract{}
+	err = r.Get(ctx, types.NamespacedName{Name: EnrolledExtractName, Namespace: ns.Name}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get Extract")
+		return ctrl.Result{}, err
+	}
+	exists := err == nil
+	if exists && found.Labels[EnrolledLabel] != "true" {
+		// An Extract with the same name was created by hand, leave it alone
+		log.Info("Extract not created by enrollment, skipping", "Extract.Namespace", found.Namespace, "Extract.Name", found.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if ns.Labels[EnabledLabel] != "true" || ns.Status.Phase == corev1.NamespaceTerminating {
+		if exists {
+			log.Info("Deleting Extract of unenrolled namespace", "Extract.Namespace", found.Namespace, "Extract.Name", found.Name)
+			if err := r.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "Failed to delete Extract", "Extract.Namespace", found.Namespace, "Extract.Name", found.Name)
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if r.Requester == "" {
+		log.Info("No requester configured for enrolled namespaces, not creating the Extract")
+		return ctrl.Result{}, nil
+	}
+
+	template, err := r.extractTemplate(ctx)
+	if err != nil {
+		log.Error(err, "Failed to load Extract template", "ConfigMap", r.Config)
+		return ctrl.Result{}, err
+	}
+	spec, err := renderExtractSpec(*template, templateVars{Namespace: ns.Name})
+	if err != nil {
+		log.Error(err, "Failed to render Extract template", "ConfigMap", r.Config)
+		return ctrl.Result{}, err
+	}
+
+	if !exists {
+		extract := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      EnrolledExtractName,
+				Namespace: ns.Name,
+				Labels:    map[string]string{EnrolledLabel: "true"},
+			},
+			Spec: spec,
+		}
+		if _, err := r.setRequester(extract); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("Creating a new Extract", "Extract.Namespace", extract.Namespace, "Extract.Name", extract.Name)
+		if err := r.Create(ctx, extract); err != nil {
+			log.Error(err, "Failed to create new Extract", "Extract.Namespace", extract.Namespace, "Extract.Name", extract.Name)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	requesterChanged, err := r.setRequester(found)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if requesterChanged || !reflect.DeepEqual(found.Spec, spec) {
+		found.Spec = spec
+		log.Info("Updating Extract", "Extract.Namespace", found.Namespace, "Extract.Name", found.Name)
+		if err := r.Update(ctx, found); err != nil {
+			log.Error(err, "Failed to update Extract", "Extract.Namespace", found.Namespace, "Extract.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setRequester records the configured requester on an enrolled Extract and
+// reports whether it changed
+func (r *NamespaceReconciler) setRequester(extract *primerv1alpha1.Extract) (bool, error) {
+	groups := r.RequesterGroups
+	if groups == nil {
+		groups = []string{}
+	}
+	groupsJSON, err := json.Marshal(groups)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for key, value := range map[string]string{
+		primerv1alpha1.RequesterAnnotation:       r.Requester,
+		primerv1alpha1.RequesterGroupsAnnotation: string(groupsJSON),
+	} {
+		if extract.Annotations[key] != value {
+			metav1.SetMetaDataAnnotation(&extract.ObjectMeta, key, value)
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// extractTemplate reads the Extract spec from the config ConfigMap
+func (r *NamespaceReconciler) extractTemplate(ctx context.Context) (*primerv1alpha1.ExtractSpec, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.configReader.Get(ctx, r.Config, cm); err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[ExtractTemplateKey]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in ConfigMap %s", ExtractTemplateKey, r.Config)
+	}
+	spec := &primerv1alpha1.ExtractSpec{}
+	if err := yaml.UnmarshalStrict([]byte(data), spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// enrolledNamespaces requeues every enrolled namespace when the config
+// ConfigMap changes so that template edits reach existing Extracts
+func (r *NamespaceReconciler) enrolledNamespaces(obj client.Object) []reconcile.Request {
+	if obj.GetName() != r.Config.Name || obj.GetNamespace() != r.Config.Namespace {
+		return nil
+	}
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(context.Background(), namespaces, client.MatchingLabels{EnabledLabel: "true"}); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+	}
+	return requests
+}
+
+// namespaceOfExtract requeues the namespace of an enrolled Extract so that
+// deleted or edited Extracts are put back
+func namespaceOfExtract(obj client.Object) []reconcile.Request {
+	if obj.GetLabels()[EnrolledLabel] != "true" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.GetNamespace()}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	configCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:    mgr.GetScheme(),
+		Mapper:    mgr.GetRESTMapper(),
+		Namespace: r.Config.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(configCache); err != nil {
+		return err
+	}
+	r.configReader = configCache
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Namespace{}).
+		Watches(&source.Kind{Type: &primerv1alpha1.Extract{}}, handler.EnqueueRequestsFromMapFunc(namespaceOfExtract)).
+		Watches(source.NewKindWithCache(&corev1.ConfigMap{}, configCache), handler.EnqueueRequestsFromMapFunc(r.enrolledNamespaces)).
+		Complete(r)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("NamespaceReconciler", func() {
+	ctx := context.Background()
+	config := types.NamespacedName{Name: "gitops-primer-config", Namespace: "enroll-config"}
+	var reconciler *NamespaceReconciler
+
+	setNamespace := func(name string, labels map[string]string) {
+		ns := &corev1.Namespace{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+			Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+			return
+		}
+		ns.Labels = labels
+		Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+	}
+	reconcileNamespace := func(name string) {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+	enrolledExtract := func(namespace string) (*primerv1alpha1.Extract, error) {
+		extract := &primerv1alpha1.Extract{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: EnrolledExtractName, Namespace: namespace}, extract)
+		return extract, err
+	}
+
+	BeforeEach(func() {
+		setNamespace(config.Namespace, nil)
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: config.Name, Namespace: config.Namespace},
+			Data: map[string]string{ExtractTemplateKey: "repo: git@example.com:org/tenants.git\n" +
+				"branch: \"{{ .Namespace }}\"\n"},
+		}
+		if err := k8sClient.Create(ctx, cm); err != nil {
+			Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+		}
+		reconciler = &NamespaceReconciler{
+			Client:          k8sClient,
+			Scheme:          scheme.Scheme,
+			Config:          config,
+			Requester:       "enroller",
+			RequesterGroups: []string{"tenants"},
+			configReader:    k8sClient,
+		}
+	})
+
+	It("creates the Extract of an enrolled namespace with the configured requester", func() {
+		setNamespace("enroll-shop", map[string]string{EnabledLabel: "true"})
+		reconcileNamespace("enroll-shop")
+
+		extract, err := enrolledExtract("enroll-shop")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(extract.Labels[EnrolledLabel]).To(Equal("true"))
+		Expect(extract.Spec.Repo).To(Equal("git@example.com:org/tenants.git"))
+		Expect(extract.Spec.Branch).To(Equal("enroll-shop"))
+		user, groups, ok := primerv1alpha1.Requester(extract)
+		Expect(ok).To(BeTrue())
+		Expect(user).To(Equal("enroller"))
+		Expect(groups).To(Equal([]string{"tenants"}))
+
+		// Edited requesters are put back
+		extract.Annotations[primerv1alpha1.RequesterAnnotation] = "someone"
+		Expect(k8sClient.Update(ctx, extract)).To(Succeed())
+		reconcileNamespace("enroll-shop")
+		extract, err = enrolledExtract("enroll-shop")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(extract.Annotations[primerv1alpha1.RequesterAnnotation]).To(Equal("enroller"))
+
+		// Removing the label deletes the Extract
+		setNamespace("enroll-shop", nil)
+		reconcileNamespace("enroll-shop")
+		_, err = enrolledExtract("enroll-shop")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("does not enroll namespaces without a configured requester", func() {
+		reconciler.Requester = ""
+		setNamespace("enroll-norequester", map[string]string{EnabledLabel: "true"})
+		reconcileNamespace("enroll-norequester")
+		_, err := enrolledExtract("enroll-norequester")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("leaves Extracts created by hand alone", func() {
+		setNamespace("enroll-manual", map[string]string{EnabledLabel: "true"})
+		manual := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{Name: EnrolledExtractName, Namespace: "enroll-manual"},
+			Spec:       primerv1alpha1.ExtractSpec{Repo: "git@example.com:org/manual.git", Branch: "main"},
+		}
+		Expect(k8sClient.Create(ctx, manual)).To(Succeed())
+		reconcileNamespace("enroll-manual")
+
+		extract, err := enrolledExtract("enroll-manual")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(extract.Spec.Repo).To(Equal("git@example.com:org/manual.git"))
+		Expect(extract.Annotations).NotTo(HaveKey(primerv1alpha1.RequesterAnnotation))
+
+		// Nor does unenrolling delete them
+		setNamespace("enroll-manual", nil)
+		reconcileNamespace("enroll-manual")
+		_, err = enrolledExtract("enroll-manual")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("requeues the enrolled namespaces when the config changes", func() {
+		setNamespace("enroll-requeue", map[string]string{EnabledLabel: "true"})
+		setNamespace("enroll-other", nil)
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: config.Name, Namespace: config.Namespace}}
+		requests := reconciler.enrolledNamespaces(cm)
+		names := []string{}
+		for _, request := range requests {
+			names = append(names, request.Name)
+		}
+		Expect(names).To(ContainElement("enroll-requeue"))
+		Expect(names).NotTo(ContainElement("enroll-other"))
+
+		other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: config.Namespace}}
+		Expect(reconciler.enrolledNamespaces(other)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,278 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/sink"
+)
+
+// networkPolicyForExtract returns the NetworkPolicy limiting the egress of
+// the extraction pod to the cluster DNS, the API server, the remote of its
+// sink and the API server of its source cluster. Hosts are resolved again for
+// every run, hosts the remote redirects to are not allowed.
+func (r *ExtractReconciler) networkPolicyForExtract(ctx context.Context, m *primerv1alpha1.Extract) (*networkingv1.NetworkPolicy, error) {
+	egress, err := r.serviceEgress(ctx, r.dnsService())
+	if err != nil {
+		return nil, fmt.Errorf("reading the cluster DNS Service %s: %w", r.dnsService(), err)
+	}
+
+	apiServer, err := r.serviceEgress(ctx, types.NamespacedName{Name: "kubernetes", Namespace: metav1.NamespaceDefault})
+	if err != nil {
+		return nil, err
+	}
+	egress = append(egress, apiServer...)
+
+	if host, port, err := remoteHostPort(m.Spec); err != nil {
+		return nil, err
+	} else if host != "" {
+		rule, err := hostEgress(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+		egress = append(egress, rule)
+	}
+
+	if m.Spec.Source != nil {
+		host, port, err := r.sourceHostPort(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		rule, err := hostEgress(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+		egress = append(egress, rule)
+	}
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "primer-extract-" + m.Name,
+			Namespace: m.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"job-name": "primer-extract-" + m.Name},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+	ctrl.SetControllerReference(m, policy, r.Scheme)
+	return policy, nil
+}
+
+// applyNetworkPolicy creates the policy, or replaces the rules of a policy
+// left behind by a previous run with the addresses resolved for this one
+func (r *ExtractReconciler) applyNetworkPolicy(ctx context.Context, policy *networkingv1.NetworkPolicy) error {
+	err := r.Create(ctx, policy)
+	if !errors.IsAlreadyExists(err) {
+		return err
+	}
+	found := &networkingv1.NetworkPolicy{}
+	if err := r.Get(ctx, types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}, found); err != nil {
+		return err
+	}
+	found.Spec = policy.Spec
+	return r.Update(ctx, found)
+}
+
+// serviceEgress allows a Service and its endpoints, since network plugins
+// differ in whether they match traffic before or after the Service address
+// is translated
+func (r *ExtractReconciler) serviceEgress(ctx context.Context, key types.NamespacedName) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	svc := &corev1.Service{}
+	if err := r.apiReader.Get(ctx, key, svc); err != nil {
+		return nil, err
+	}
+	endpoints := &corev1.Endpoints{}
+	if err := r.apiReader.Get(ctx, key, endpoints); err != nil {
+		return nil, err
+	}
+
+	rules := []networkingv1.NetworkPolicyEgressRule{}
+	if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+		rule := networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{ipBlock(svc.Spec.ClusterIP)}}
+		for _, port := range svc.Spec.Ports {
+			rule.Ports = append(rule.Ports, protocolPort(port.Protocol, port.Port))
+		}
+		rules = append(rules, rule)
+	}
+	for _, subset := range endpoints.Subsets {
+		rule := networkingv1.NetworkPolicyEgressRule{}
+		for _, address := range subset.Addresses {
+			rule.To = append(rule.To, ipBlock(address.IP))
+		}
+		for _, port := range subset.Ports {
+			rule.Ports = append(rule.Ports, protocolPort(port.Protocol, port.Port))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// dnsService returns the Service of the cluster DNS, kube-dns unless
+// configured otherwise
+func (r *ExtractReconciler) dnsService() types.NamespacedName {
+	if r.DNS.Name == "" {
+		return types.NamespacedName{Name: "kube-dns", Namespace: metav1.NamespaceSystem}
+	}
+	return r.DNS
+}
+
+// hostEgress allows the addresses host resolves to on port
+func hostEgress(ctx context.Context, host string, port int32) (networkingv1.NetworkPolicyEgressRule, error) {
+	rule := networkingv1.NetworkPolicyEgressRule{Ports: []networkingv1.NetworkPolicyPort{tcpPort(port)}}
+	if ip := net.ParseIP(host); ip != nil {
+		rule.To = append(rule.To, ipBlock(ip.String()))
+		return rule, nil
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return rule, err
+	}
+	for _, ip := range ips {
+		rule.To = append(rule.To, ipBlock(ip.IP.String()))
+	}
+	return rule, nil
+}
+
+// sourceHostPort returns the host and port of the API server of the current
+// context of the source kubeconfig
+func (r *ExtractReconciler) sourceHostPort(ctx context.Context, m *primerv1alpha1.Extract) (string, int32, error) {
+	ref := m.Spec.Source.KubeconfigSecretRef
+	secret := &corev1.Secret{}
+	if err := r.apiReader.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: m.Namespace}, secret); err != nil {
+		return "", 0, err
+	}
+	config, err := clientcmd.Load(secret.Data[ref.Key])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid kubeconfig in key %s of Secret %s: %w", ref.Key, ref.Name, err)
+	}
+	current, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return "", 0, fmt.Errorf("the kubeconfig in Secret %s has no current context", ref.Name)
+	}
+	cluster, ok := config.Clusters[current.Cluster]
+	if !ok {
+		return "", 0, fmt.Errorf("the kubeconfig in Secret %s has no cluster %q", ref.Name, current.Cluster)
+	}
+	u, err := url.Parse(cluster.Server)
+	if err != nil {
+		return "", 0, err
+	}
+	port := int32(443)
+	if u.Scheme == "http" {
+		port = 80
+	}
+	return splitHostPort(u.Host, port)
+}
+
+// remoteHostPort returns the host and port the sink connects to, or an
+// empty host for the in-cluster sinks
+func remoteHostPort(spec primerv1alpha1.ExtractSpec) (string, int32, error) {
+	remote := spec.Remote()
+	if remote == "" {
+		return "", 0, nil
+	}
+	if spec.Sink != nil && spec.Sink.Type == primerv1alpha1.SinkOCI {
+		host, err := sink.RegistryHost(remote)
+		if err != nil {
+			return "", 0, err
+		}
+		port := int32(443)
+		if spec.Sink.OCI.Insecure {
+			port = 80
+		}
+		return splitHostPort(host, port)
+	}
+	if !strings.Contains(remote, "://") {
+		// scp like git remotes use SSH
+		host := remote[:strings.IndexAny(remote+":", ":/")]
+		if i := strings.LastIndexByte(host, '@'); i >= 0 {
+			host = host[i+1:]
+		}
+		return host, 22, nil
+	}
+	u, err := url.Parse(remote)
+	if err != nil {
+		return "", 0, err
+	}
+	defaults := map[string]int32{"ssh": 22, "git": 9418, "http": 80, "https": 443}
+	port, ok := defaults[u.Scheme]
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported scheme in %s", remote)
+	}
+	if u.Port() != "" {
+		parsed, err := strconv.ParseInt(u.Port(), 10, 32)
+		if err != nil {
+			return "", 0, err
+		}
+		port = int32(parsed)
+	}
+	return u.Hostname(), port, nil
+}
+
+// splitHostPort splits an optional port off a registry host
+func splitHostPort(hostport string, port int32) (string, int32, error) {
+	host, portText, err := net.SplitHostPort(hostport)
+	if err != nil {
+		// No port given
+		return hostport, port, nil
+	}
+	parsed, err := strconv.ParseInt(portText, 10, 32)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, int32(parsed), nil
+}
+
+func ipBlock(ip string) networkingv1.NetworkPolicyPeer {
+	cidr := ip + "/32"
+	if strings.Contains(ip, ":") {
+		cidr = ip + "/128"
+	}
+	return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}
+}
+
+func tcpPort(port int32) networkingv1.NetworkPolicyPort {
+	return protocolPort(corev1.ProtocolTCP, port)
+}
+
+func protocolPort(protocol corev1.Protocol, port int32) networkingv1.NetworkPolicyPort {
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	p := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
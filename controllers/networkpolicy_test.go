@@ -0,0 +1,193 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = DescribeTable("remoteHostPort",
+	func(spec primerv1alpha1.ExtractSpec, host string, port int) {
+		gotHost, gotPort, err := remoteHostPort(spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gotHost).To(Equal(host))
+		Expect(gotPort).To(BeEquivalentTo(port))
+	},
+	Entry("scp like git remote", primerv1alpha1.ExtractSpec{Repo: "git@github.com:org/repo.git"}, "github.com", 22),
+	Entry("ssh remote with a port", primerv1alpha1.ExtractSpec{Repo: "ssh://git@git.example.com:2222/org/repo.git"}, "git.example.com", 2222),
+	Entry("https remote", primerv1alpha1.ExtractSpec{Repo: "https://git.example.com/org/repo.git"}, "git.example.com", 443),
+	Entry("s3 endpoint", primerv1alpha1.ExtractSpec{Sink: &primerv1alpha1.ExtractSink{
+		Type: primerv1alpha1.SinkS3,
+		S3:   &primerv1alpha1.S3Sink{Endpoint: "http://minio.example.com:9000", Bucket: "primer"},
+	}}, "minio.example.com", 9000),
+	Entry("oci registry", primerv1alpha1.ExtractSpec{Sink: &primerv1alpha1.ExtractSink{
+		Type: primerv1alpha1.SinkOCI,
+		OCI:  &primerv1alpha1.OCISink{Repository: "registry.example.com/team/manifests"},
+	}}, "registry.example.com", 443),
+	Entry("insecure oci registry with a port", primerv1alpha1.ExtractSpec{Sink: &primerv1alpha1.ExtractSink{
+		Type: primerv1alpha1.SinkOCI,
+		OCI:  &primerv1alpha1.OCISink{Repository: "registry.example.com:5000/team/manifests", Insecure: true},
+	}}, "registry.example.com", 5000),
+	Entry("in-cluster sink", primerv1alpha1.ExtractSpec{Sink: &primerv1alpha1.ExtractSink{Type: primerv1alpha1.SinkPVC}}, "", 0),
+)
+
+var _ = DescribeTable("sshEnv",
+	func(repo string, env []corev1.EnvVar) {
+		Expect(sshEnv(repo)).To(Equal(env))
+	},
+	Entry("scp like git remote", "git@gitlab.example.com:org/repo.git", []corev1.EnvVar{
+		{Name: "SSH_HOST", Value: "gitlab.example.com"},
+		{Name: "SSH_PORT", Value: "22"},
+	}),
+	Entry("ssh remote with a port", "ssh://git@git.example.com:2222/org/repo.git", []corev1.EnvVar{
+		{Name: "SSH_HOST", Value: "git.example.com"},
+		{Name: "SSH_PORT", Value: "2222"},
+	}),
+	Entry("https remote", "https://git.example.com/org/repo.git", nil),
+	Entry("no remote", "", nil),
+)
+
+var _ = Describe("networkPolicyForExtract", func() {
+	ctx := context.Background()
+	const namespace = "networkpolicy"
+	var reconciler *ExtractReconciler
+
+	ensure := func(obj client.Object) {
+		if err := k8sClient.Create(ctx, obj); err != nil && !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	}
+	cidrs := func(rule networkingv1.NetworkPolicyEgressRule) []string {
+		result := []string{}
+		for _, peer := range rule.To {
+			result = append(result, peer.IPBlock.CIDR)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		ensure(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		ensure(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: namespace},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.0.0.53",
+				Ports: []corev1.ServicePort{
+					{Name: "dns", Protocol: corev1.ProtocolUDP, Port: 53},
+					{Name: "dns-tcp", Protocol: corev1.ProtocolTCP, Port: 53},
+				},
+			},
+		})
+		ensure(&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: namespace},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{{IP: "10.1.0.5"}},
+				Ports:     []corev1.EndpointPort{{Name: "dns", Protocol: corev1.ProtocolUDP, Port: 53}},
+			}},
+		})
+		// envtest runs an API server with the kubernetes Service, the fake
+		// client needs it created
+		ensure(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: metav1.NamespaceDefault},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.0.0.1",
+				Ports:     []corev1.ServicePort{{Name: "https", Protocol: corev1.ProtocolTCP, Port: 443}},
+			},
+		})
+		ensure(&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: metav1.NamespaceDefault}})
+
+		reconciler = &ExtractReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			DNS:       types.NamespacedName{Name: "dns", Namespace: namespace},
+			apiReader: k8sClient,
+		}
+	})
+
+	It("only allows the cluster DNS, the API server and the remote", func() {
+		extract := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{Name: "primer", Namespace: namespace, UID: "1"},
+			Spec:       primerv1alpha1.ExtractSpec{Repo: "https://192.0.2.10:8443/org/repo.git"},
+		}
+		policy, err := reconciler.networkPolicyForExtract(ctx, extract)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy.Name).To(Equal("primer-extract-primer"))
+		Expect(policy.Spec.PodSelector.MatchLabels).To(Equal(map[string]string{"job-name": "primer-extract-primer"}))
+		Expect(policy.Spec.PolicyTypes).To(Equal([]networkingv1.PolicyType{networkingv1.PolicyTypeEgress}))
+
+		egress := policy.Spec.Egress
+		// Every rule names its peers, none allows any address
+		for _, rule := range egress {
+			Expect(rule.To).NotTo(BeEmpty())
+		}
+		Expect(cidrs(egress[0])).To(Equal([]string{"10.0.0.53/32"}))
+		Expect(egress[0].Ports).To(Equal([]networkingv1.NetworkPolicyPort{
+			protocolPort(corev1.ProtocolUDP, 53),
+			protocolPort(corev1.ProtocolTCP, 53),
+		}))
+		Expect(cidrs(egress[1])).To(Equal([]string{"10.1.0.5/32"}))
+		Expect(egress[1].Ports).To(Equal([]networkingv1.NetworkPolicyPort{protocolPort(corev1.ProtocolUDP, 53)}))
+
+		remote := egress[len(egress)-1]
+		Expect(cidrs(remote)).To(Equal([]string{"192.0.2.10/32"}))
+		Expect(remote.Ports).To(Equal([]networkingv1.NetworkPolicyPort{tcpPort(8443)}))
+	})
+
+	It("fails without the cluster DNS Service", func() {
+		reconciler.DNS = types.NamespacedName{Name: "missing", Namespace: namespace}
+		extract := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{Name: "primer", Namespace: namespace},
+			Spec:       primerv1alpha1.ExtractSpec{Repo: "https://192.0.2.10/org/repo.git"},
+		}
+		_, err := reconciler.networkPolicyForExtract(ctx, extract)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("replaces the rules of a policy left behind by a previous run", func() {
+		extract := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: namespace, UID: "2"},
+			Spec:       primerv1alpha1.ExtractSpec{Repo: "https://192.0.2.20/org/repo.git"},
+		}
+		ensure(&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "primer-extract-stale", Namespace: namespace},
+			Spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress:      []networkingv1.NetworkPolicyEgressRule{{To: []networkingv1.NetworkPolicyPeer{ipBlock("192.0.2.99")}}},
+			},
+		})
+
+		policy, err := reconciler.networkPolicyForExtract(ctx, extract)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reconciler.applyNetworkPolicy(ctx, policy)).To(Succeed())
+
+		found := &networkingv1.NetworkPolicy{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "primer-extract-stale", Namespace: namespace}, found)).To(Succeed())
+		Expect(found.Spec.Egress).To(Equal(policy.Spec.Egress))
+	})
+})
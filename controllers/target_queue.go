@@ -0,0 +1,146 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"path"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/allowlist"
+)
+
+// targetQueue lets a single extraction run per repository and branch, so
+// that Extracts sharing a branch do not race each other's pushes. Waiting
+// Extracts get the target in the order they asked for it and are queued
+// through events once it is released.
+type targetQueue struct {
+	mu sync.Mutex
+	// running maps targets to the Extract whose extraction runs
+	running map[string]types.NamespacedName
+	// waiting holds the Extracts waiting for a target, in order
+	waiting map[string][]types.NamespacedName
+	events  chan event.GenericEvent
+}
+
+func newTargetQueue() *targetQueue {
+	return &targetQueue{
+		running: map[string]types.NamespacedName{},
+		waiting: map[string][]types.NamespacedName{},
+		events:  make(chan event.GenericEvent),
+	}
+}
+
+// targetKey returns the normalized repository and branch the git sink of an
+// Extract pushes to, or "" for the other sinks
+func targetKey(m *primerv1alpha1.Extract) string {
+	if m.Spec.Sink != nil && m.Spec.Sink.Type != "" && m.Spec.Sink.Type != primerv1alpha1.SinkGit {
+		return ""
+	}
+	host, p := allowlist.Normalize(m.Spec.Repo)
+	return path.Join(host, p) + "@" + m.Spec.Branch
+}
+
+// acquire reports whether the extraction of key may run, which is the case
+// when the target is free and key is next in line. Otherwise key is added to
+// the waiting Extracts and the holder of the target is returned.
+func (q *targetQueue) acquire(target string, key types.NamespacedName) (bool, types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// Extracts that changed their target stop waiting for the previous one
+	q.remove(key, target)
+	if holder, ok := q.running[target]; ok && holder != key {
+		q.enqueue(target, key)
+		return false, holder
+	}
+	if waiting := q.waiting[target]; len(waiting) > 0 && waiting[0] != key {
+		q.enqueue(target, key)
+		return false, waiting[0]
+	}
+	q.remove(key, "")
+	q.running[target] = key
+	return true, key
+}
+
+// claim records key as running the extraction of target unless another
+// Extract holds it, for extractions found running after a restart
+func (q *targetQueue) claim(target string, key types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.running[target]; !ok {
+		q.running[target] = key
+	}
+}
+
+// release frees the targets held by key and removes it from the waiting
+// Extracts, the next waiting Extract of a freed target is queued
+func (q *targetQueue) release(key types.NamespacedName) {
+	q.mu.Lock()
+	next := []types.NamespacedName{}
+	for target, holder := range q.running {
+		if holder != key {
+			continue
+		}
+		delete(q.running, target)
+		if waiting := q.waiting[target]; len(waiting) > 0 {
+			next = append(next, waiting[0])
+		}
+	}
+	q.remove(key, "")
+	q.mu.Unlock()
+
+	for _, n := range next {
+		extract := &primerv1alpha1.Extract{}
+		extract.Name = n.Name
+		extract.Namespace = n.Namespace
+		// Do not block the reconcile releasing the target
+		go func() { q.events <- event.GenericEvent{Object: extract} }()
+	}
+}
+
+// enqueue adds key to the Extracts waiting for target unless it waits already
+func (q *targetQueue) enqueue(target string, key types.NamespacedName) {
+	for _, waiting := range q.waiting[target] {
+		if waiting == key {
+			return
+		}
+	}
+	q.waiting[target] = append(q.waiting[target], key)
+}
+
+// remove drops key from the waiting lists of all targets but except
+func (q *targetQueue) remove(key types.NamespacedName, except string) {
+	for target, waiting := range q.waiting {
+		if target == except {
+			continue
+		}
+		for i, w := range waiting {
+			if w == key {
+				waiting = append(waiting[:i], waiting[i+1:]...)
+				break
+			}
+		}
+		if len(waiting) == 0 {
+			delete(q.waiting, target)
+		} else {
+			q.waiting[target] = waiting
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("targetQueue", func() {
+	const target = "github.com/org/repo@main"
+	var (
+		q       *targetQueue
+		a, b, c types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		q = newTargetQueue()
+		a = types.NamespacedName{Name: "a", Namespace: "test"}
+		b = types.NamespacedName{Name: "b", Namespace: "test"}
+		c = types.NamespacedName{Name: "c", Namespace: "test"}
+	})
+
+	// queued returns the Extract queued by the next event
+	queued := func() types.NamespacedName {
+		var e event.GenericEvent
+		Eventually(q.events).Should(Receive(&e))
+		return types.NamespacedName{Name: e.Object.GetName(), Namespace: e.Object.GetNamespace()}
+	}
+
+	It("lets one Extract hold a target", func() {
+		ok, holder := q.acquire(target, a)
+		Expect(ok).To(BeTrue())
+		Expect(holder).To(Equal(a))
+		// Acquiring again keeps the target
+		ok, _ = q.acquire(target, a)
+		Expect(ok).To(BeTrue())
+
+		ok, holder = q.acquire(target, b)
+		Expect(ok).To(BeFalse())
+		Expect(holder).To(Equal(a))
+		// Other targets are independent
+		ok, _ = q.acquire("github.com/org/repo@stage", b)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("hands a released target to the waiting Extracts in order", func() {
+		q.acquire(target, a)
+		q.acquire(target, b)
+		q.acquire(target, c)
+		// Asking again keeps the place in line
+		q.acquire(target, b)
+		Expect(q.waiting[target]).To(Equal([]types.NamespacedName{b, c}))
+
+		q.release(a)
+		Expect(queued()).To(Equal(b))
+		// c is not next in line even though the target is free
+		ok, holder := q.acquire(target, c)
+		Expect(ok).To(BeFalse())
+		Expect(holder).To(Equal(b))
+		ok, _ = q.acquire(target, b)
+		Expect(ok).To(BeTrue())
+		Expect(q.waiting[target]).To(Equal([]types.NamespacedName{c}))
+
+		q.release(b)
+		Expect(queued()).To(Equal(c))
+		ok, _ = q.acquire(target, c)
+		Expect(ok).To(BeTrue())
+		Expect(q.waiting).To(BeEmpty())
+	})
+
+	It("drops released Extracts from the waiting ones", func() {
+		q.acquire(target, a)
+		q.acquire(target, b)
+		q.acquire(target, c)
+		q.release(b)
+		Expect(q.waiting[target]).To(Equal([]types.NamespacedName{c}))
+		Expect(q.running[target]).To(Equal(a))
+		Consistently(q.events).ShouldNot(Receive())
+	})
+
+	It("removes Extracts that changed their target from the previous one", func() {
+		q.acquire(target, a)
+		q.acquire(target, b)
+		q.acquire("github.com/org/repo@stage", a)
+		ok, _ := q.acquire("github.com/org/repo@stage", b)
+		Expect(ok).To(BeFalse())
+		Expect(q.waiting).To(Equal(map[string][]types.NamespacedName{"github.com/org/repo@stage": {b}}))
+
+		q.remove(b, "")
+		Expect(q.waiting).To(BeEmpty())
+	})
+
+	It("keeps the target of a claimed extraction", func() {
+		q.claim(target, a)
+		ok, holder := q.acquire(target, b)
+		Expect(ok).To(BeFalse())
+		Expect(holder).To(Equal(a))
+		// Claims do not take a held target
+		q.claim(target, c)
+		Expect(q.running[target]).To(Equal(a))
+
+		q.release(a)
+		Expect(queued()).To(Equal(b))
+	})
+
+	It("keys targets by the normalized repository and branch", func() {
+		ssh := &primerv1alpha1.Extract{Spec: primerv1alpha1.ExtractSpec{Repo: "git@github.com:org/repo.git", Branch: "main"}}
+		https := &primerv1alpha1.Extract{Spec: primerv1alpha1.ExtractSpec{Repo: "https://github.com/org/repo", Branch: "main"}}
+		Expect(targetKey(ssh)).To(Equal(targetKey(https)))
+		s3 := &primerv1alpha1.Extract{Spec: primerv1alpha1.ExtractSpec{Sink: &primerv1alpha1.ExtractSink{Type: primerv1alpha1.SinkS3}}}
+		Expect(targetKey(s3)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("ExtractReconciler waiting for a target", func() {
+	ctx := context.Background()
+
+	It("only updates the status when the Queued condition changes", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "queued"}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		extract := &primerv1alpha1.Extract{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "waiting",
+				Namespace: ns.Name,
+				Annotations: map[string]string{
+					primerv1alpha1.RequesterAnnotation:       "alice",
+					primerv1alpha1.RequesterGroupsAnnotation: "[]",
+				},
+			},
+			Spec: primerv1alpha1.ExtractSpec{Repo: "git@github.com:org/repo.git", Branch: "main"},
+		}
+		Expect(k8sClient.Create(ctx, extract)).To(Succeed())
+		reconciler := &ExtractReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Config:    types.NamespacedName{Name: "gitops-primer-config", Namespace: ns.Name},
+			changes:   newChangeWatcher(ctrl.Log, nil, nil),
+			apiReader: k8sClient,
+			queue:     newTargetQueue(),
+		}
+		holder := types.NamespacedName{Name: "holder", Namespace: ns.Name}
+		ok, _ := reconciler.queue.acquire(targetKey(extract), holder)
+		Expect(ok).To(BeTrue())
+
+		key := client.ObjectKeyFromObject(extract)
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(queuedRequeueInterval))
+		Expect(k8sClient.Get(ctx, key, extract)).To(Succeed())
+		Expect(extract.Status.Conditions.IsTrueFor(primerv1alpha1.ConditionQueued)).To(BeTrue())
+		version := extract.ResourceVersion
+
+		// Polling while the holder keeps the target writes nothing
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, extract)).To(Succeed())
+		Expect(extract.ResourceVersion).To(Equal(version))
+	})
+})
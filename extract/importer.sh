@@ -0,0 +1,9 @@
+#!/bin/bash
+set -e
+
+# Setup SSH for cloning the repository
+source /ssh.sh
+
+# Clone the repository of the Import, apply its objects and record the
+# results in the status of the Import
+primer import --workdir /repo
@@ -0,0 +1,33 @@
+#!/bin/bash
+# Setup SSH for cloning and pushing with the key mounted from the Secret
+if [ -f /keys/id_rsa ]; then
+  mkdir -p ~/.ssh/controlmasters
+  chmod 711 ~/.ssh
+  # Trust the host keys stored along with the key, or scan the remote host
+  if [ -f /keys/known_hosts ]; then
+    cp /keys/known_hosts ~/.ssh/known_hosts
+  elif [ -n "${SSH_HOST}" ]; then
+    echo "Warning: /keys/known_hosts is missing, trusting the host keys scanned from ${SSH_HOST}"
+    ssh-keyscan -p "${SSH_PORT:-22}" "${SSH_HOST}" >> ~/.ssh/known_hosts
+  fi
+  cat - <<SSHCONFIG > ~/.ssh/config
+Host *
+  # Wait max 30s to establish connection
+  ConnectTimeout 30
+  # Control persist to speed 2nd ssh connection
+  ControlMaster auto
+  ControlPath ~/.ssh/controlmasters/%C
+  ControlPersist 5
+  # Disables warning when IP is added to known_hosts
+  CheckHostIP no
+  # Refuse hosts missing from known_hosts
+  StrictHostKeyChecking yes
+  # Use the identity provided via attached Secret
+  IdentityFile /keys/id_rsa
+  # Enable protocol-level keepalive to detect connection failure
+  ServerAliveCountMax 4
+  ServerAliveInterval 30
+  # Using protocol-level, so we don't need TCP-level
+  TCPKeepAlive no
+SSHCONFIG
+fi
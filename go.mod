@@ -3,6 +3,7 @@ module github.com/cooktheryan/gitops-primer
 go 1.15
 
 require (
+	filippo.io/age v1.0.0
 	github.com/go-logr/logr v0.4.0
 	github.com/gophercloud/gophercloud v0.1.0
 	github.com/onsi/ginkgo v1.14.1
@@ -10,8 +11,12 @@ require (
 	github.com/openshift/custom-resource-status v1.1.0
 	github.com/operator-framework/operator-lib v0.1.0
 	github.com/prometheus/client_golang v1.7.1
+	go.mozilla.org/sops/v3 v3.7.3
+	golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4
+	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/api v0.20.2
 	k8s.io/apimachinery v0.20.2
 	k8s.io/client-go v0.20.2
 	sigs.k8s.io/controller-runtime v0.8.3
+	sigs.k8s.io/yaml v1.2.0
 )
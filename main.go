@@ -18,18 +18,22 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
 	"github.com/cooktheryan/gitops-primer/controllers"
@@ -52,11 +56,32 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var configName string
+	var configNamespace string
+	var managerUsername string
+	var dnsName string
+	var enrollmentRequester string
+	var enrollmentRequesterGroups string
+	var dnsNamespace string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&configName, "config-name", "gitops-primer-config",
+		"The name of the ConfigMap holding the Extract template for enrolled namespaces and the repository allowlist.")
+	flag.StringVar(&configNamespace, "config-namespace", "gitops-primer-system",
+		"The namespace of the ConfigMap holding the Extract template for enrolled namespaces and the repository allowlist.")
+	flag.StringVar(&managerUsername, "manager-username", serviceAccountUsername(),
+		"The user the manager runs as. The requester webhook keeps the requester it copies from ExtractSets to Extracts.")
+	flag.StringVar(&dnsName, "dns-service-name", "kube-dns",
+		"The name of the Service of the cluster DNS extraction pods may query.")
+	flag.StringVar(&dnsNamespace, "dns-service-namespace", "kube-system",
+		"The namespace of the Service of the cluster DNS extraction pods may query.")
+	flag.StringVar(&enrollmentRequester, "enrollment-requester", "",
+		"The user the Extracts of enrolled namespaces run as. Namespaces are not enrolled without it.")
+	flag.StringVar(&enrollmentRequesterGroups, "enrollment-requester-groups", "",
+		"The comma separated groups of the enrollment requester.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,6 +90,15 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if enrollmentRequester != "" && enrollmentRequester == managerUsername {
+		setupLog.Error(fmt.Errorf("the enrollment requester is the manager"), "enrolled Extracts must not run with the permissions of the manager")
+		os.Exit(1)
+	}
+	var enrollmentGroups []string
+	if enrollmentRequesterGroups != "" {
+		enrollmentGroups = strings.Split(enrollmentRequesterGroups, ",")
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -81,10 +115,47 @@ func main() {
 	if err = (&controllers.ExtractReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+		Config: types.NamespacedName{Name: configName, Namespace: configNamespace},
+		DNS:    types.NamespacedName{Name: dnsName, Namespace: dnsNamespace},
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Extract")
 		os.Exit(1)
 	}
+	if err = (&controllers.ExtractSetReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ExtractSet")
+		os.Exit(1)
+	}
+	if err = (&controllers.NamespaceReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Config:          types.NamespacedName{Name: configName, Namespace: configNamespace},
+		Requester:       enrollmentRequester,
+		RequesterGroups: enrollmentGroups,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Namespace")
+		os.Exit(1)
+	}
+	if err = (&controllers.ImportReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Import")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		mgr.GetWebhookServer().Register("/mutate-primer-gitops-io-v1alpha1-requester", &webhook.Admission{
+			Handler: &primerv1alpha1.RequesterRecorder{ManagerUsername: managerUsername},
+		})
+		mgr.GetWebhookServer().Register("/validate-primer-gitops-io-v1alpha1-repo", &webhook.Admission{
+			Handler: &primerv1alpha1.RepoValidator{
+				Reader: mgr.GetAPIReader(),
+				Config: types.NamespacedName{Name: configName, Namespace: configNamespace},
+			},
+		})
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -102,3 +173,13 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// serviceAccountUsername returns the user of the service account the manager
+// runs as, read from the environment set in config/manager
+func serviceAccountUsername() string {
+	namespace, name := os.Getenv("POD_NAMESPACE"), os.Getenv("SERVICE_ACCOUNT")
+	if namespace == "" || name == "" {
+		return ""
+	}
+	return "system:serviceaccount:" + namespace + ":" + name
+}
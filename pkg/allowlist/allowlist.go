@@ -0,0 +1,139 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package allowlist restricts the remotes extractions may push to.
+package allowlist
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Key is the key of the manager ConfigMap holding the allowlist
+const Key = "repoAllowlist"
+
+var (
+	// scpLike matches git remotes written as [user@]host:path
+	scpLike = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.*)$`)
+	// hostPort matches references starting with host:port, such as image
+	// repositories of registries on other ports
+	hostPort = regexp.MustCompile(`^[^@/:]+:[0-9]+(/|$)`)
+)
+
+// Allowlist holds the host and path patterns of the allowed remotes, such as
+// github.com/example/* or *.internal.example.com. Hosts and path segments are
+// matched with path.Match, a pattern without a path allows every path of the
+// host. A nil Allowlist allows everything.
+type Allowlist struct {
+	Patterns []string
+}
+
+// Parse reads an allowlist written as YAML list of patterns
+func Parse(data string) (*Allowlist, error) {
+	patterns := []string{}
+	if err := yaml.UnmarshalStrict([]byte(data), &patterns); err != nil {
+		return nil, err
+	}
+	for _, pattern := range patterns {
+		host, p := split(strings.TrimSpace(pattern))
+		if _, err := path.Match(host, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return &Allowlist{Patterns: patterns}, nil
+}
+
+// Load reads the allowlist from the manager ConfigMap. It returns nil when
+// neither the ConfigMap nor the key exist.
+func Load(ctx context.Context, reader client.Reader, config types.NamespacedName) (*Allowlist, error) {
+	cm := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, config, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, ok := cm.Data[Key]
+	if !ok {
+		return nil, nil
+	}
+	return Parse(data)
+}
+
+// Allows reports whether a remote matches one of the patterns. Remotes may
+// be URLs, scp like git remotes or host/path references such as image
+// repositories.
+func (a *Allowlist) Allows(remote string) bool {
+	if a == nil {
+		return true
+	}
+	host, p := Normalize(remote)
+	for _, pattern := range a.Patterns {
+		patternHost, patternPath := split(strings.TrimSpace(pattern))
+		if ok, _ := path.Match(strings.ToLower(patternHost), host); !ok {
+			continue
+		}
+		if patternPath == "" {
+			return true
+		}
+		if ok, _ := path.Match(strings.TrimSuffix(patternPath, ".git"), p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns the lower case host without port and the path of a
+// remote without leading slash and .git suffix
+func Normalize(remote string) (string, string) {
+	var host, p string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", ""
+		}
+		host, p = u.Hostname(), u.Path
+	} else if m := scpLike.FindStringSubmatch(remote); m != nil && !hostPort.MatchString(remote) {
+		host, p = m[1], m[2]
+	} else {
+		host, p = split(remote)
+		host = strings.Split(host, ":")[0]
+	}
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	return strings.ToLower(host), p
+}
+
+// split separates the host from the path
+func split(s string) (string, string) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package allowlist
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Allowlist", func() {
+	It("normalizes the forms of remotes", func() {
+		for remote, expected := range map[string][2]string{
+			"git@github.com:Example/apps.git":           {"github.com", "Example/apps"},
+			"ssh://git@GitHub.com:22/example/apps.git":  {"github.com", "example/apps"},
+			"https://gitlab.example.com/group/sub/apps": {"gitlab.example.com", "group/sub/apps"},
+			"registry.example.com:5000/team/manifests":  {"registry.example.com", "team/manifests"},
+			"quay.io/team/manifests":                    {"quay.io", "team/manifests"},
+		} {
+			host, p := Normalize(remote)
+			Expect([2]string{host, p}).To(Equal(expected), remote)
+		}
+	})
+
+	It("matches hosts and paths", func() {
+		allowlist, err := Parse("- github.com/example/*\n- '*.internal.example.com'\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowlist.Allows("git@github.com:example/apps.git")).To(BeTrue())
+		Expect(allowlist.Allows("https://github.com/example/apps")).To(BeTrue())
+		Expect(allowlist.Allows("git@github.com:attacker/apps.git")).To(BeFalse())
+		Expect(allowlist.Allows("git@github.com:example/apps/nested.git")).To(BeFalse())
+		Expect(allowlist.Allows("ssh://git@git.internal.example.com/any/repo.git")).To(BeTrue())
+		Expect(allowlist.Allows("git@internal.example.com.attacker.io:repo.git")).To(BeFalse())
+	})
+
+	It("allows everything without configuration", func() {
+		var allowlist *Allowlist
+		Expect(allowlist.Allows("git@github.com:attacker/apps.git")).To(BeTrue())
+
+		empty, err := Parse("[]")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(empty.Allows("git@github.com:example/apps.git")).To(BeFalse())
+	})
+
+	It("rejects invalid patterns", func() {
+		_, err := Parse("- github.com/[example\n")
+		Expect(err).To(MatchError(ContainSubstring("invalid pattern")))
+	})
+
+	It("loads the allowlist from the manager ConfigMap", func() {
+		config := types.NamespacedName{Name: "gitops-primer-config", Namespace: "gitops-primer-system"}
+		reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		allowlist, err := Load(context.Background(), reader, config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowlist).To(BeNil())
+
+		reader = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: config.Name, Namespace: config.Namespace},
+			Data:       map[string]string{Key: "- github.com/example/*\n"},
+		}).Build()
+		allowlist, err = Load(context.Background(), reader, config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowlist.Patterns).To(Equal([]string{"github.com/example/*"}))
+	})
+})
@@ -0,0 +1,44 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// parseAgeRecipients parses age1 public keys, one per line. Empty lines and
+// lines starting with # are skipped.
+func parseAgeRecipients(data []byte) ([]string, error) {
+	recipients := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := age.ParseX25519Recipient(line); err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", line, err)
+		}
+		recipients = append(recipients, line)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no age recipients")
+	}
+	return recipients, nil
+}
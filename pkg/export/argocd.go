@@ -0,0 +1,176 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"encoding/json"
+	"path"
+
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const (
+	argoCDAPIVersion       = "argoproj.io/v1alpha1"
+	defaultArgoCDDirectory = "argocd"
+	defaultArgoCDNamespace = "argocd"
+	defaultArgoCDProject   = "default"
+	defaultArgoCDServer    = "https://kubernetes.default.svc"
+	defaultApplicationSet  = "gitops-primer"
+)
+
+// applicationSetEntry is the git files generator entry of a namespace. Its
+// keys are the parameters used by the ApplicationSet template.
+type applicationSetEntry struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Path      string `json:"path"`
+}
+
+// renderArgoCD adds the Argo CD manifests deploying the tree's root from the
+// repo and branch of the spec. Applications are written to
+// <directory>/<namespace>.yaml. ApplicationSets are written to
+// <directory>/applicationset.yaml with one entry per namespace below
+// <directory>/namespaces. The directory is relative to base.
+func renderArgoCD(tree *Tree, namespace, base string, spec *primerv1alpha1.ExtractSpec) error {
+	argo := spec.Output.ArgoCD
+	dir := argo.Directory
+	if dir == "" {
+		dir = defaultArgoCDDirectory
+	}
+	dir = path.Join(base, dir)
+	destNamespace := namespace
+	if argo.Destination != nil && argo.Destination.Namespace != "" {
+		destNamespace = argo.Destination.Namespace
+	}
+
+	if argo.Kind != "ApplicationSet" {
+		name := argo.Name
+		if name == "" {
+			name = namespace
+		}
+		app := map[string]interface{}{
+			"apiVersion": argoCDAPIVersion,
+			"kind":       "Application",
+			"metadata":   argoCDMetadata(argo, name),
+			"spec":       applicationSpec(argo, spec, tree.Root, destNamespace),
+		}
+		data, err := yaml.Marshal(app)
+		if err != nil {
+			return err
+		}
+		tree.Add(dir, fileName(namespace), data)
+		return nil
+	}
+
+	name := argo.Name
+	if name == "" {
+		name = defaultApplicationSet
+	}
+	entry, err := json.MarshalIndent(applicationSetEntry{
+		Name:      namespace,
+		Namespace: destNamespace,
+		Path:      tree.Root,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tree.Add(path.Join(dir, "namespaces"), safeName(namespace)+".json", append(entry, '\n'))
+
+	appSet := map[string]interface{}{
+		"apiVersion": argoCDAPIVersion,
+		"kind":       "ApplicationSet",
+		"metadata":   argoCDMetadata(argo, name),
+		"spec": map[string]interface{}{
+			"generators": []interface{}{
+				map[string]interface{}{
+					"git": map[string]interface{}{
+						"repoURL":  spec.Repo,
+						"revision": spec.Branch,
+						"files": []interface{}{
+							map[string]interface{}{"path": path.Join(dir, "namespaces", "*.json")},
+						},
+					},
+				},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "{{name}}"},
+				"spec":     applicationSpec(argo, spec, "{{path}}", "{{namespace}}"),
+			},
+		},
+	}
+	data, err := yaml.Marshal(appSet)
+	if err != nil {
+		return err
+	}
+	tree.Add(dir, "applicationset.yaml", data)
+	return nil
+}
+
+func argoCDMetadata(argo *primerv1alpha1.ArgoCDOutput, name string) map[string]interface{} {
+	namespace := argo.Namespace
+	if namespace == "" {
+		namespace = defaultArgoCDNamespace
+	}
+	return map[string]interface{}{"name": name, "namespace": namespace}
+}
+
+// applicationSpec is the spec of an Application deploying sourcePath
+func applicationSpec(argo *primerv1alpha1.ArgoCDOutput, spec *primerv1alpha1.ExtractSpec, sourcePath, destNamespace string) map[string]interface{} {
+	project := argo.Project
+	if project == "" {
+		project = defaultArgoCDProject
+	}
+	destination := map[string]interface{}{"namespace": destNamespace}
+	switch {
+	case argo.Destination != nil && argo.Destination.Name != "":
+		destination["name"] = argo.Destination.Name
+	case argo.Destination != nil && argo.Destination.Server != "":
+		destination["server"] = argo.Destination.Server
+	default:
+		destination["server"] = defaultArgoCDServer
+	}
+
+	appSpec := map[string]interface{}{
+		"project": project,
+		"source": map[string]interface{}{
+			"repoURL":        spec.Repo,
+			"targetRevision": spec.Branch,
+			"path":           sourcePath,
+		},
+		"destination": destination,
+	}
+	if policy := argo.SyncPolicy; policy != nil {
+		syncPolicy := map[string]interface{}{}
+		if policy.Automated != nil {
+			syncPolicy["automated"] = map[string]interface{}{
+				"prune":    policy.Automated.Prune,
+				"selfHeal": policy.Automated.SelfHeal,
+			}
+		}
+		if len(policy.SyncOptions) > 0 {
+			options := make([]interface{}, 0, len(policy.SyncOptions))
+			for _, option := range policy.SyncOptions {
+				options = append(options, option)
+			}
+			syncPolicy["syncOptions"] = options
+		}
+		appSpec["syncPolicy"] = syncPolicy
+	}
+	return appSpec
+}
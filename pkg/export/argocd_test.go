@@ -0,0 +1,108 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"encoding/json"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("Argo CD", func() {
+	newSpec := func(argo *primerv1alpha1.ArgoCDOutput) *primerv1alpha1.ExtractSpec {
+		return &primerv1alpha1.ExtractSpec{
+			Repo:   "git@github.com:example/apps.git",
+			Branch: "main",
+			Output: &primerv1alpha1.ExtractOutput{
+				Format: primerv1alpha1.OutputKustomize,
+				ArgoCD: argo,
+			},
+		}
+	}
+
+	It("writes an Application pointing at the layout", func() {
+		objs, err := Decode(strings.NewReader(workloads))
+		Expect(err).NotTo(HaveOccurred())
+		tree, err := Run(objs, "test", newSpec(&primerv1alpha1.ArgoCDOutput{
+			Enabled: true,
+			Project: "apps",
+			SyncPolicy: &primerv1alpha1.ArgoCDSyncPolicy{
+				Automated: &primerv1alpha1.ArgoCDAutomatedSync{Prune: true},
+			},
+		}), Keys{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Owned).To(Equal([]string{"base"}))
+
+		app := &unstructured.Unstructured{}
+		Expect(yaml.Unmarshal(tree.Files["argocd/test.yaml"], &app.Object)).To(Succeed())
+		Expect(app.GetKind()).To(Equal("Application"))
+		Expect(app.GetName()).To(Equal("test"))
+		Expect(app.GetNamespace()).To(Equal("argocd"))
+		spec, _, _ := unstructured.NestedMap(app.Object, "spec")
+		Expect(spec).To(Equal(map[string]interface{}{
+			"project": "apps",
+			"source": map[string]interface{}{
+				"repoURL":        "git@github.com:example/apps.git",
+				"targetRevision": "main",
+				"path":           "base",
+			},
+			"destination": map[string]interface{}{
+				"server":    "https://kubernetes.default.svc",
+				"namespace": "test",
+			},
+			"syncPolicy": map[string]interface{}{
+				"automated": map[string]interface{}{"prune": true, "selfHeal": false},
+			},
+		}))
+	})
+
+	It("writes an ApplicationSet entry per namespace", func() {
+		objs, err := Decode(strings.NewReader(workloads))
+		Expect(err).NotTo(HaveOccurred())
+		tree, err := Run(objs, "test", newSpec(&primerv1alpha1.ArgoCDOutput{
+			Enabled:     true,
+			Kind:        "ApplicationSet",
+			Directory:   "gitops",
+			Destination: &primerv1alpha1.ArgoCDDestination{Name: "prod"},
+		}), Keys{})
+		Expect(err).NotTo(HaveOccurred())
+
+		entry := map[string]string{}
+		Expect(json.Unmarshal(tree.Files["gitops/namespaces/test.json"], &entry)).To(Succeed())
+		Expect(entry).To(Equal(map[string]string{"name": "test", "namespace": "test", "path": "base"}))
+
+		appSet := &unstructured.Unstructured{}
+		Expect(yaml.Unmarshal(tree.Files["gitops/applicationset.yaml"], &appSet.Object)).To(Succeed())
+		Expect(appSet.GetKind()).To(Equal("ApplicationSet"))
+		Expect(appSet.GetName()).To(Equal("gitops-primer"))
+		generators, _, _ := unstructured.NestedSlice(appSet.Object, "spec", "generators")
+		Expect(generators).To(HaveLen(1))
+		Expect(generators[0]).To(HaveKeyWithValue("git", map[string]interface{}{
+			"repoURL":  "git@github.com:example/apps.git",
+			"revision": "main",
+			"files":    []interface{}{map[string]interface{}{"path": "gitops/namespaces/*.json"}},
+		}))
+		destination, _, _ := unstructured.NestedMap(appSet.Object, "spec", "template", "spec", "destination")
+		Expect(destination).To(Equal(map[string]interface{}{"name": "prod", "namespace": "{{namespace}}"}))
+	})
+})
@@ -0,0 +1,72 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package export turns the objects of a namespace into the files that are
+// stored in the repository. Objects are filtered and sanitized before they
+// are rendered into the configured output layout.
+package export
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// Run filters and sanitizes the objects of a namespace, applies the secret
+// policy and renders them as configured by the Extract spec below the
+// directory of its path
+func Run(objs []*unstructured.Unstructured, namespace string, spec *primerv1alpha1.ExtractSpec, keys Keys) (*Tree, error) {
+	dir, err := Directory(spec)
+	if err != nil {
+		return nil, err
+	}
+	objs = Filter(objs)
+	for _, obj := range objs {
+		Sanitize(obj)
+	}
+	format := primerv1alpha1.OutputFlat
+	if spec.Output != nil && spec.Output.Format != "" {
+		format = spec.Output.Format
+	}
+	objs, err = ProtectSecrets(objs, spec.Secrets, format, keys)
+	if err != nil {
+		return nil, err
+	}
+	Sort(objs)
+	tree, err := Render(objs, namespace, spec.Output)
+	if err != nil {
+		return nil, err
+	}
+	tree.Objects = len(objs)
+	if dir != "" {
+		tree.Prefix(dir)
+	}
+	if spec.Output != nil && spec.Output.ArgoCD != nil && spec.Output.ArgoCD.Enabled {
+		if err := renderArgoCD(tree, namespace, dir, spec); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Output != nil && spec.Output.Flux != nil && spec.Output.Flux.Enabled {
+		if err := renderFlux(tree, namespace, dir, spec); err != nil {
+			return nil, err
+		}
+	}
+	// Extractions sharing a repository must not touch each other's files
+	if err := tree.Confine(dir); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
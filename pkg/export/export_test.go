@@ -0,0 +1,297 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const namespaceDump = `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: web
+    namespace: test
+    uid: 0b5a3c1e
+    resourceVersion: "42"
+    generation: 3
+    labels:
+      primer.gitops.io/import: restore
+    annotations:
+      deployment.kubernetes.io/revision: "3"
+  spec:
+    replicas: 2
+  status:
+    readyReplicas: 2
+- apiVersion: apps/v1
+  kind: ReplicaSet
+  metadata:
+    name: web-5d4f8
+    namespace: test
+    ownerReferences:
+    - apiVersion: apps/v1
+      kind: Deployment
+      name: web
+      uid: 0b5a3c1e
+      controller: true
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: kube-root-ca.crt
+    namespace: test
+  data:
+    ca.crt: cert
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: test
+  labels:
+    app: web
+data:
+  b.properties: b=2
+  a.properties: a=1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: test
+spec:
+  clusterIP: 10.0.0.12
+  ports:
+  - port: 8080
+    nodePort: 30123
+`
+
+func loadDump() []*unstructured.Unstructured {
+	objs, err := Decode(strings.NewReader(namespaceDump))
+	Expect(err).NotTo(HaveOccurred())
+	return objs
+}
+
+var _ = Describe("Export", func() {
+	It("drops generated and controller owned objects", func() {
+		names := []string{}
+		for _, obj := range Filter(loadDump()) {
+			names = append(names, obj.GetKind()+"/"+obj.GetName())
+		}
+		Expect(names).To(ConsistOf("Deployment/web", "ConfigMap/settings", "Service/web"))
+	})
+
+	It("removes cluster populated fields", func() {
+		objs := loadDump()
+		Sanitize(objs[0])
+		Expect(objs[0].Object).To(Equal(map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "test"},
+			"spec":       map[string]interface{}{"replicas": int64(2)},
+		}))
+
+		service := objs[4]
+		Sanitize(service)
+		Expect(service.Object["spec"]).To(Equal(map[string]interface{}{
+			"ports": []interface{}{map[string]interface{}{"port": int64(8080)}},
+		}))
+	})
+
+	Context("with the kustomize format", func() {
+		spec := &primerv1alpha1.ExtractSpec{
+			Output: &primerv1alpha1.ExtractOutput{Format: primerv1alpha1.OutputKustomize},
+		}
+
+		It("writes a base listing every object", func() {
+			tree, err := Run(loadDump(), "test", spec, Keys{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree.Owned).To(Equal([]string{"base"}))
+			Expect(tree.Paths()).To(Equal([]string{
+				"base/configmaps/settings/a.properties",
+				"base/configmaps/settings/b.properties",
+				"base/deployment.apps-web.yaml",
+				"base/kustomization.yaml",
+				"base/service-web.yaml",
+			}))
+
+			k := kustomization{}
+			Expect(yaml.Unmarshal(tree.Files["base/kustomization.yaml"], &k)).To(Succeed())
+			Expect(k.Namespace).To(Equal("test"))
+			Expect(k.Resources).To(Equal([]string{"service-web.yaml", "deployment.apps-web.yaml"}))
+			Expect(k.ConfigMapGenerator).To(Equal([]configMapGeneratorArgs{{
+				Name:  "settings",
+				Files: []string{"configmaps/settings/a.properties", "configmaps/settings/b.properties"},
+				Options: &generatorOptions{
+					Labels:                map[string]string{"app": "web"},
+					DisableNameSuffixHash: true,
+				},
+			}}))
+			Expect(string(tree.Files["base/configmaps/settings/a.properties"])).To(Equal("a=1"))
+		})
+
+		It("produces the same files on every run", func() {
+			first, err := Run(loadDump(), "test", spec, Keys{})
+			Expect(err).NotTo(HaveOccurred())
+			objs := loadDump()
+			// Reverse the input order
+			for i, j := 0, len(objs)-1; i < j; i, j = i+1, j-1 {
+				objs[i], objs[j] = objs[j], objs[i]
+			}
+			second, err := Run(objs, "test", spec, Keys{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second.Files).To(Equal(first.Files))
+		})
+
+		It("replaces files of objects that no longer exist", func() {
+			dir, err := ioutil.TempDir("", "export")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			stale := filepath.Join(dir, "base", "secret-removed.yaml")
+			Expect(os.MkdirAll(filepath.Dir(stale), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(stale, []byte("stale"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("keep"), 0644)).To(Succeed())
+
+			tree, err := Run(loadDump(), "test", spec, Keys{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree.Write(dir)).To(Succeed())
+
+			Expect(stale).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(dir, "README.md")).To(BeAnExistingFile())
+			Expect(filepath.Join(dir, "base", "kustomization.yaml")).To(BeAnExistingFile())
+		})
+	})
+
+	It("writes crane style file names with the flat format", func() {
+		tree, err := Run(loadDump(), "test", &primerv1alpha1.ExtractSpec{}, Keys{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Paths()).To(Equal([]string{
+			"resources/test/ConfigMap_v1_test_settings.yaml",
+			"resources/test/Deployment_apps_v1_test_web.yaml",
+			"resources/test/Service_v1_test_web.yaml",
+		}))
+	})
+
+	It("lays out objects of other clusters below clusters/<name>", func() {
+		spec := &primerv1alpha1.ExtractSpec{
+			Output: &primerv1alpha1.ExtractOutput{Format: primerv1alpha1.OutputKustomize},
+			Source: &primerv1alpha1.ExtractSource{
+				KubeconfigSecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "prod-kubeconfig"},
+					Key:                  "value",
+				},
+				ClusterName: "prod",
+			},
+		}
+		tree, err := Run(loadDump(), "test", spec, Keys{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Root).To(Equal("clusters/prod/base"))
+		Expect(tree.Owned).To(Equal([]string{"clusters/prod/base"}))
+		Expect(tree.Files).To(HaveKey("clusters/prod/base/kustomization.yaml"))
+	})
+
+	It("confines the tree to the directory of the path", func() {
+		spec := &primerv1alpha1.ExtractSpec{
+			Path: "teams/{{.Namespace}}/{{.Name}}",
+			Output: &primerv1alpha1.ExtractOutput{
+				Format: primerv1alpha1.OutputKustomize,
+				Flux:   &primerv1alpha1.FluxOutput{Enabled: true},
+			},
+			Repo:   "https://example.com/org/repo.git",
+			Branch: "main",
+		}
+		var err error
+		spec.Path, err = RenderPath(spec, PathVars{Namespace: "test", Name: "primer"})
+		Expect(err).NotTo(HaveOccurred())
+		tree, err := Run(loadDump(), "test", spec, Keys{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Root).To(Equal("teams/test/primer/base"))
+		Expect(tree.Owned).To(Equal([]string{"teams/test/primer/base"}))
+		for _, p := range tree.Paths() {
+			Expect(p).To(HavePrefix("teams/test/primer/"))
+		}
+	})
+
+	It("never writes or prunes through symlinks", func() {
+		dir, err := ioutil.TempDir("", "primer-tree")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		outside, err := ioutil.TempDir("", "primer-outside")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outside)
+		Expect(ioutil.WriteFile(filepath.Join(outside, "keep.yaml"), []byte("kept\n"), 0644)).To(Succeed())
+		Expect(os.Symlink(outside, filepath.Join(dir, "teams"))).To(Succeed())
+
+		// A symlinked parent of an owned directory
+		tree := NewTree()
+		tree.Owned = []string{"teams/base"}
+		Expect(tree.Write(dir)).To(MatchError(ContainSubstring("through the symlink")))
+
+		// A symlinked parent of a file
+		tree = NewTree()
+		tree.Add("teams", "keep.yaml", []byte("overwritten\n"))
+		Expect(tree.Write(dir)).To(MatchError(ContainSubstring("through the symlink")))
+
+		// A symlinked file
+		Expect(os.Symlink(filepath.Join(outside, "keep.yaml"), filepath.Join(dir, "keep.yaml"))).To(Succeed())
+		tree = NewTree()
+		tree.Add("", "keep.yaml", []byte("overwritten\n"))
+		Expect(tree.Write(dir)).To(MatchError(ContainSubstring("through the symlink")))
+
+		data, err := ioutil.ReadFile(filepath.Join(outside, "keep.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("kept\n"))
+	})
+
+	It("refuses paths escaping the repository root", func() {
+		for _, p := range []string{"../other", "/etc", "team/../../other"} {
+			_, err := Run(loadDump(), "test", &primerv1alpha1.ExtractSpec{Path: p}, Keys{})
+			Expect(err).To(MatchError(ContainSubstring("escapes the repository root")), p)
+		}
+	})
+
+	It("refuses output directories outside of the path", func() {
+		spec := &primerv1alpha1.ExtractSpec{
+			Path: "team",
+			Output: &primerv1alpha1.ExtractOutput{
+				ArgoCD: &primerv1alpha1.ArgoCDOutput{Enabled: true, Directory: "../argocd"},
+			},
+			Repo:   "https://example.com/org/repo.git",
+			Branch: "main",
+		}
+		_, err := Run(loadDump(), "test", spec, Keys{})
+		Expect(err).To(MatchError(ContainSubstring("outside of the directory")))
+	})
+
+	It("fails on unknown path variables", func() {
+		_, err := RenderPath(&primerv1alpha1.ExtractSpec{Path: "{{.Cluster}}"}, PathVars{})
+		Expect(err).To(HaveOccurred())
+	})
+})
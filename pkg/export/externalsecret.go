@@ -0,0 +1,161 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const (
+	defaultExternalSecretKey      = "{{ .Namespace }}/{{ .Name }}"
+	defaultExternalSecretProperty = "{{ .Key }}"
+	defaultExternalSecretRefresh  = time.Hour
+)
+
+// externalSecretVars are the fields available to the key and property templates
+type externalSecretVars struct {
+	Namespace string
+	Name      string
+	Key       string
+}
+
+// externalSecretConverter turns Secrets into ExternalSecrets
+type externalSecretConverter struct {
+	spec     *primerv1alpha1.ExternalSecretSpec
+	key      *template.Template
+	property *template.Template
+}
+
+func newExternalSecretConverter(spec *primerv1alpha1.ExternalSecretSpec) (*externalSecretConverter, error) {
+	if spec == nil || spec.SecretStoreRef.Name == "" {
+		return nil, fmt.Errorf("the ExternalSecret secret policy requires a secretStoreRef")
+	}
+	keyText := spec.KeyTemplate
+	if keyText == "" {
+		keyText = defaultExternalSecretKey
+	}
+	propertyText := defaultExternalSecretProperty
+	if spec.PropertyTemplate != nil {
+		propertyText = *spec.PropertyTemplate
+	}
+	key, err := template.New("keyTemplate").Option("missingkey=error").Parse(keyText)
+	if err != nil {
+		return nil, err
+	}
+	property, err := template.New("propertyTemplate").Option("missingkey=error").Parse(propertyText)
+	if err != nil {
+		return nil, err
+	}
+	return &externalSecretConverter{spec: spec, key: key, property: property}, nil
+}
+
+// convert returns an ExternalSecret recreating the Secret from the store.
+// Only the keys of the Secret are used, its values are dropped.
+func (c *externalSecretConverter) convert(secret *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	keys := []string{}
+	for _, field := range []string{"data", "stringData"} {
+		values, _, _ := unstructured.NestedMap(secret.Object, field)
+		for key := range values {
+			if !containsString(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	data := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		vars := externalSecretVars{Namespace: secret.GetNamespace(), Name: secret.GetName(), Key: key}
+		remoteKey, err := executeTemplate(c.key, vars)
+		if err != nil {
+			return nil, err
+		}
+		remoteRef := map[string]interface{}{"key": remoteKey}
+		property, err := executeTemplate(c.property, vars)
+		if err != nil {
+			return nil, err
+		}
+		if property != "" {
+			remoteRef["property"] = property
+		}
+		data = append(data, map[string]interface{}{"secretKey": key, "remoteRef": remoteRef})
+	}
+
+	storeKind := c.spec.SecretStoreRef.Kind
+	if storeKind == "" {
+		storeKind = "SecretStore"
+	}
+	refresh := defaultExternalSecretRefresh
+	if c.spec.RefreshInterval != nil {
+		refresh = c.spec.RefreshInterval.Duration
+	}
+	targetTemplate := map[string]interface{}{}
+	if secretType, ok, _ := unstructured.NestedString(secret.Object, "type"); ok {
+		targetTemplate["type"] = secretType
+	}
+	templateMeta := map[string]interface{}{}
+	if labels, ok, _ := unstructured.NestedMap(secret.Object, "metadata", "labels"); ok {
+		templateMeta["labels"] = labels
+	}
+	if annotations, ok, _ := unstructured.NestedMap(secret.Object, "metadata", "annotations"); ok {
+		templateMeta["annotations"] = annotations
+	}
+	if len(templateMeta) > 0 {
+		targetTemplate["metadata"] = templateMeta
+	}
+	target := map[string]interface{}{
+		"name":           secret.GetName(),
+		"creationPolicy": "Owner",
+	}
+	if len(targetTemplate) > 0 {
+		target["template"] = targetTemplate
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1alpha1",
+		"kind":       "ExternalSecret",
+		"metadata": map[string]interface{}{
+			"name":      secret.GetName(),
+			"namespace": secret.GetNamespace(),
+		},
+		"spec": map[string]interface{}{
+			"refreshInterval": refresh.String(),
+			"secretStoreRef": map[string]interface{}{
+				"name": c.spec.SecretStoreRef.Name,
+				"kind": storeKind,
+			},
+			"target": target,
+			"data":   data,
+		},
+	}}, nil
+}
+
+func executeTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
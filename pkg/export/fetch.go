@@ -0,0 +1,67 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+)
+
+// Fetch lists the objects of namespace for every listable resource of the
+// discovered resource lists, like crane export does. Resources that cannot be
+// listed with the credentials of the client are skipped and returned as
+// resource.group.
+func Fetch(ctx context.Context, client dynamic.Interface, resources []*metav1.APIResourceList, namespace string) ([]*unstructured.Unstructured, []string, error) {
+	objs := []*unstructured.Unstructured{}
+	skipped := []string{}
+	for _, list := range resources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, resource := range list.APIResources {
+			if !resource.Namespaced || strings.Contains(resource.Name, "/") || !sets.NewString(resource.Verbs...).Has("list") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			items, err := client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+			if errors.IsForbidden(err) || errors.IsNotFound(err) || errors.IsMethodNotSupported(err) {
+				skipped = append(skipped, gvr.GroupResource().String())
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			for i := range items.Items {
+				obj := &items.Items[i]
+				// Items of lists may come without their type
+				obj.SetAPIVersion(gv.String())
+				obj.SetKind(resource.Kind)
+				objs = append(objs, obj)
+			}
+		}
+	}
+	Sort(objs)
+	return objs, skipped, nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Fetch", func() {
+	object := func(apiVersion, kind, namespace, name string) runtime.Object {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+
+	It("lists the namespaced resources and skips forbidden ones", func() {
+		client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{
+				{Version: "v1", Resource: "configmaps"}:                 "ConfigMapList",
+				{Version: "v1", Resource: "secrets"}:                    "SecretList",
+				{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+			},
+			object("v1", "ConfigMap", "test", "settings"),
+			object("v1", "ConfigMap", "other", "settings"),
+			object("apps/v1", "Deployment", "test", "web"),
+		)
+		client.PrependReactor("list", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", nil)
+		})
+		list := []string{"get", "list"}
+		resources := []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: list},
+				{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: list},
+				{Name: "namespaces", Kind: "Namespace", Verbs: list},
+				{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+			}},
+			{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: list},
+			}},
+		}
+
+		objs, skipped, err := Fetch(context.Background(), client, resources, "test")
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, obj := range objs {
+			names = append(names, obj.GetAPIVersion()+" "+obj.GetKind()+" "+obj.GetNamespace()+"/"+obj.GetName())
+		}
+		Expect(names).To(Equal([]string{"v1 ConfigMap test/settings", "apps/v1 Deployment test/web"}))
+		Expect(skipped).To(Equal([]string{"secrets"}))
+	})
+})
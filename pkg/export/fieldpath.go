@@ -0,0 +1,149 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fieldPath is a parsed JSONPath expression limited to field names, [index]
+// and [*] so that the selected fields can be written as well as read
+type fieldPath []pathSegment
+
+type pathSegment struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseFieldPath parses expressions such as
+// {.spec.template.spec.containers[*].image} or .metadata.labels['app']
+func parseFieldPath(expr string) (fieldPath, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	path := fieldPath{}
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '.':
+			i++
+			end := i
+			for end < len(s) && s[end] != '.' && s[end] != '[' {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("invalid path %q: empty field name", expr)
+			}
+			path = append(path, pathSegment{field: s[i:end]})
+			i = end
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", expr)
+			}
+			inner := s[i+1 : i+end]
+			i += end + 1
+			switch {
+			case inner == "*":
+				path = append(path, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, pathSegment{field: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid path %q: unsupported subscript [%s]", expr, inner)
+				}
+				path = append(path, pathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", expr, s[i])
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("invalid path %q: no fields selected", expr)
+	}
+	return path, nil
+}
+
+// hasWildcard reports whether the path can select several fields per object
+func (p fieldPath) hasWildcard() bool {
+	for _, seg := range p {
+		if seg.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldRef points at a field of a map or an element of a slice
+type fieldRef struct {
+	m     map[string]interface{}
+	key   string
+	s     []interface{}
+	index int
+}
+
+func (f fieldRef) get() interface{} {
+	if f.m != nil {
+		return f.m[f.key]
+	}
+	return f.s[f.index]
+}
+
+func (f fieldRef) set(value interface{}) {
+	if f.m != nil {
+		f.m[f.key] = value
+		return
+	}
+	f.s[f.index] = value
+}
+
+// find returns the existing fields selected by the path in document order
+func (p fieldPath) find(obj interface{}) []fieldRef {
+	refs := []fieldRef{}
+	var walk func(value interface{}, segs fieldPath, ref *fieldRef)
+	walk = func(value interface{}, segs fieldPath, ref *fieldRef) {
+		if len(segs) == 0 {
+			if ref != nil {
+				refs = append(refs, *ref)
+			}
+			return
+		}
+		seg := segs[0]
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if seg.isIndex || seg.wildcard {
+				return
+			}
+			if child, ok := v[seg.field]; ok {
+				walk(child, segs[1:], &fieldRef{m: v, key: seg.field})
+			}
+		case []interface{}:
+			if seg.wildcard {
+				for i := range v {
+					walk(v[i], segs[1:], &fieldRef{s: v, index: i})
+				}
+			} else if seg.isIndex && seg.index < len(v) {
+				walk(v[seg.index], segs[1:], &fieldRef{s: v, index: seg.index})
+			}
+		}
+	}
+	walk(obj, p, nil)
+	return refs
+}
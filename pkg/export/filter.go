@@ -0,0 +1,184 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// excludedKinds are never written, they are either generated at runtime or
+// only describe the current state of the cluster
+var excludedKinds = map[string]bool{
+	"Event":                       true,
+	"Endpoints":                   true,
+	"EndpointSlice":               true,
+	"Pod":                         true,
+	"PodMetrics":                  true,
+	"Lease":                       true,
+	"PipelineRun":                 true,
+	"TaskRun":                     true,
+	"PodNetworkConnectivityCheck": true,
+	"ImageStreamTag":              true,
+	"ImageTag":                    true,
+}
+
+// generatedNames are objects created for every namespace by Kubernetes or
+// OpenShift, keyed by kind
+var generatedNames = map[string]map[string]bool{
+	"ConfigMap":      {"kube-root-ca.crt": true, "openshift-service-ca.crt": true},
+	"ServiceAccount": {"default": true, "builder": true, "deployer": true, "pipeline": true},
+	"RoleBinding":    {"system:image-pullers": true, "system:image-builders": true, "system:deployers": true},
+}
+
+// generatedSecretTypes are Secrets populated by the service account controllers
+var generatedSecretTypes = map[string]bool{
+	"kubernetes.io/service-account-token": true,
+	"kubernetes.io/dockercfg":             true,
+}
+
+// Filter drops the objects that should not be stored: runtime state, objects
+// created for every namespace, objects managed by a controller and the
+// objects of the extraction itself
+func Filter(objs []*unstructured.Unstructured) []*unstructured.Unstructured {
+	kept := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if !excluded(obj) {
+			kept = append(kept, obj)
+		}
+	}
+	return kept
+}
+
+// InNamespace keeps the objects of namespace, dropping cluster scoped objects
+// and those of other namespaces found in dumps of a whole cluster
+func InNamespace(objs []*unstructured.Unstructured, namespace string) []*unstructured.Unstructured {
+	kept := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if obj.GetNamespace() == namespace {
+			kept = append(kept, obj)
+		}
+	}
+	return kept
+}
+
+func excluded(obj *unstructured.Unstructured) bool {
+	kind := obj.GetKind()
+	if excludedKinds[kind] {
+		return true
+	}
+	if generatedNames[kind][obj.GetName()] {
+		return true
+	}
+	if strings.HasPrefix(obj.GetName(), "primer-extract-") || strings.HasPrefix(obj.GetName(), "primer-import-") {
+		return true
+	}
+	if kind == "Secret" {
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		if generatedSecretTypes[secretType] {
+			return true
+		}
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Controller != nil && *ref.Controller {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterAnnotations are set by the cluster and have no meaning elsewhere
+var clusterAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+	"openshift.io/generated-by",
+}
+
+// Sanitize removes the fields the API server populates so that the object
+// can be applied to another cluster or namespace
+func Sanitize(obj *unstructured.Unstructured) {
+	for _, field := range []string{"uid", "resourceVersion", "selfLink", "creationTimestamp", "generation", "managedFields", "ownerReferences", "deletionTimestamp", "deletionGracePeriodSeconds"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	annotations := obj.GetAnnotations()
+	for _, annotation := range clusterAnnotations {
+		delete(annotations, annotation)
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	} else {
+		obj.SetAnnotations(annotations)
+	}
+	// Objects restored by an Import are extracted like any other object
+	if labels := obj.GetLabels(); labels[primerv1alpha1.ImportLabel] != "" {
+		delete(labels, primerv1alpha1.ImportLabel)
+		if len(labels) == 0 {
+			unstructured.RemoveNestedField(obj.Object, "metadata", "labels")
+		} else {
+			obj.SetLabels(labels)
+		}
+	}
+	unstructured.RemoveNestedField(obj.Object, "status")
+
+	switch obj.GetKind() {
+	case "Service":
+		// Cluster IPs are allocated by the cluster unless the service is headless
+		if ip, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); ip != "None" {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		}
+		if ports, ok, _ := unstructured.NestedSlice(obj.Object, "spec", "ports"); ok {
+			serviceType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+			if serviceType != "NodePort" && serviceType != "LoadBalancer" {
+				for _, port := range ports {
+					if p, ok := port.(map[string]interface{}); ok {
+						delete(p, "nodePort")
+					}
+				}
+				unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+			}
+		}
+	case "PersistentVolumeClaim":
+		unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+	case "ServiceAccount":
+		// Token and registry secrets are generated for the service account
+		unstructured.RemoveNestedField(obj.Object, "secrets")
+		if pullSecrets, ok, _ := unstructured.NestedSlice(obj.Object, "imagePullSecrets"); ok {
+			kept := []interface{}{}
+			for _, ref := range pullSecrets {
+				if r, ok := ref.(map[string]interface{}); ok {
+					if name, _ := r["name"].(string); strings.HasPrefix(name, obj.GetName()+"-dockercfg-") {
+						continue
+					}
+				}
+				kept = append(kept, ref)
+			}
+			if len(kept) == 0 {
+				unstructured.RemoveNestedField(obj.Object, "imagePullSecrets")
+			} else {
+				unstructured.SetNestedSlice(obj.Object, kept, "imagePullSecrets")
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const (
+	defaultFluxDirectory = "flux"
+	defaultFluxNamespace = "flux-system"
+	defaultFluxInterval  = 10 * time.Minute
+)
+
+// scpLikeURL matches git URLs such as git@github.com:org/repo.git, which
+// Flux only accepts in their ssh:// form
+var scpLikeURL = regexp.MustCompile(`^([^@/:]+@)?([^/:]+):([^/].*)$`)
+
+// renderFlux adds a GitRepository and a Kustomization deploying the tree's
+// root from the repo and branch of the spec to <directory>/<namespace>.yaml
+// below base
+func renderFlux(tree *Tree, namespace, base string, spec *primerv1alpha1.ExtractSpec) error {
+	if spec.Output.Format == primerv1alpha1.OutputHelm {
+		return fmt.Errorf("flux output requires the flat or kustomize format")
+	}
+	flux := spec.Output.Flux
+	dir := flux.Directory
+	if dir == "" {
+		dir = defaultFluxDirectory
+	}
+	dir = path.Join(base, dir)
+	name := flux.Name
+	if name == "" {
+		name = namespace
+	}
+	fluxNamespace := flux.Namespace
+	if fluxNamespace == "" {
+		fluxNamespace = defaultFluxNamespace
+	}
+	interval := defaultFluxInterval
+	if flux.Interval != nil {
+		interval = flux.Interval.Duration
+	}
+	targetNamespace := flux.TargetNamespace
+	if targetNamespace == "" {
+		targetNamespace = namespace
+	}
+	metadata := map[string]interface{}{"name": name, "namespace": fluxNamespace}
+
+	repoSpec := map[string]interface{}{
+		"url":      fluxRepoURL(spec.Repo),
+		"ref":      map[string]interface{}{"branch": spec.Branch},
+		"interval": interval.String(),
+	}
+	if flux.SecretRef != "" {
+		repoSpec["secretRef"] = map[string]interface{}{"name": flux.SecretRef}
+	}
+	repo, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "source.toolkit.fluxcd.io/v1beta1",
+		"kind":       "GitRepository",
+		"metadata":   metadata,
+		"spec":       repoSpec,
+	})
+	if err != nil {
+		return err
+	}
+	kustomization, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "kustomize.toolkit.fluxcd.io/v1beta1",
+		"kind":       "Kustomization",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"interval":        interval.String(),
+			"path":            "./" + tree.Root,
+			"prune":           flux.Prune,
+			"targetNamespace": targetNamespace,
+			"sourceRef": map[string]interface{}{
+				"kind": "GitRepository",
+				"name": name,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	data := append(repo, []byte("---\n")...)
+	tree.Add(dir, fileName(namespace), append(data, kustomization...))
+	return nil
+}
+
+// fluxRepoURL rewrites scp like git URLs to ssh:// URLs
+func fluxRepoURL(repo string) string {
+	m := scpLikeURL.FindStringSubmatch(repo)
+	if m == nil {
+		return repo
+	}
+	return "ssh://" + m[1] + m[2] + "/" + m[3]
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+var _ = Describe("Flux", func() {
+	spec := &primerv1alpha1.ExtractSpec{
+		Repo:   "git@github.com:example/apps.git",
+		Branch: "main",
+		Output: &primerv1alpha1.ExtractOutput{
+			Flux: &primerv1alpha1.FluxOutput{
+				Enabled:   true,
+				Directory: "clusters/prod",
+				SecretRef: "apps-deploy-key",
+				Prune:     true,
+			},
+		},
+	}
+
+	It("writes a GitRepository and Kustomization for the extracted path", func() {
+		objs, err := Decode(strings.NewReader(workloads))
+		Expect(err).NotTo(HaveOccurred())
+		tree, err := Run(objs, "test", spec, Keys{})
+		Expect(err).NotTo(HaveOccurred())
+
+		written, err := Decode(bytes.NewReader(tree.Files["clusters/prod/test.yaml"]))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(written).To(HaveLen(2))
+		repo, kustomization := written[0], written[1]
+
+		Expect(repo.GetKind()).To(Equal("GitRepository"))
+		Expect(repo.GetNamespace()).To(Equal("flux-system"))
+		Expect(repo.Object["spec"]).To(Equal(map[string]interface{}{
+			"url":       "ssh://git@github.com/example/apps.git",
+			"ref":       map[string]interface{}{"branch": "main"},
+			"interval":  "10m0s",
+			"secretRef": map[string]interface{}{"name": "apps-deploy-key"},
+		}))
+
+		Expect(kustomization.GetKind()).To(Equal("Kustomization"))
+		Expect(kustomization.GetName()).To(Equal("test"))
+		Expect(kustomization.Object["spec"]).To(Equal(map[string]interface{}{
+			"interval":        "10m0s",
+			"path":            "./resources/test",
+			"prune":           true,
+			"targetNamespace": "test",
+			"sourceRef":       map[string]interface{}{"kind": "GitRepository", "name": "test"},
+		}))
+	})
+
+	It("keeps URLs with a scheme", func() {
+		Expect(fluxRepoURL("https://github.com/example/apps.git")).To(Equal("https://github.com/example/apps.git"))
+		Expect(fluxRepoURL("ssh://git@github.com/example/apps.git")).To(Equal("ssh://git@github.com/example/apps.git"))
+	})
+})
@@ -0,0 +1,243 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// helmChart is the directory of the helm layout
+const helmChart = "chart"
+
+// defaultHelmValues parameterize container images, replica counts and the
+// namespace when no rules are configured
+var defaultHelmValues = []primerv1alpha1.HelmValueRule{
+	{
+		Name:  "images",
+		Path:  "{.spec.template.spec.containers[*].image}",
+		Kinds: []string{"Deployment", "StatefulSet", "DaemonSet"},
+	},
+	{
+		Name:  "replicas",
+		Path:  "{.spec.replicas}",
+		Kinds: []string{"Deployment", "StatefulSet"},
+	},
+	{
+		Name:   "namespace",
+		Path:   "{.metadata.namespace}",
+		Shared: true,
+	},
+}
+
+// chartMetadata is the subset of Chart.yaml written by the helm layout
+type chartMetadata struct {
+	APIVersion  string `json:"apiVersion"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Version     string `json:"version"`
+}
+
+// helmFuncs are the template functions used by the generated templates. The
+// chart is verified with these, they behave like the Helm functions of the
+// same name.
+var helmFuncs = template.FuncMap{
+	"toJson": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// templateEscaper keeps template delimiters found in object data literal
+var templateEscaper = strings.NewReplacer("{{", `{{ "{{" }}`, "}}", `{{ "}}" }}`)
+
+// valueRule is a HelmValueRule with its path parsed
+type valueRule struct {
+	primerv1alpha1.HelmValueRule
+	path fieldPath
+}
+
+// renderHelm writes a Helm chart with one template per object. The fields
+// selected by the value rules are moved into values.yaml. The chart is
+// rendered again and compared with the objects before it is returned.
+func renderHelm(objs []*unstructured.Unstructured, namespace string, helm *primerv1alpha1.HelmOutput) (*Tree, error) {
+	chartName := namespace
+	rules := defaultHelmValues
+	if helm != nil {
+		if helm.ChartName != "" {
+			chartName = helm.ChartName
+		}
+		if len(helm.Values) > 0 {
+			rules = helm.Values
+		}
+	}
+	parsed := make([]valueRule, 0, len(rules))
+	for _, rule := range rules {
+		p, err := parseFieldPath(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("helm value %s: %w", rule.Name, err)
+		}
+		parsed = append(parsed, valueRule{HelmValueRule: rule, path: p})
+	}
+
+	tree := NewTree()
+	tree.Owned = []string{helmChart}
+	tree.Root = helmChart
+	values := map[string]interface{}{}
+	templates := map[string]string{}
+	for _, obj := range objs {
+		name := resourceFileName(obj)
+		text, err := helmTemplate(obj, parsed, values)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		templates[name] = text
+		tree.Add(path.Join(helmChart, "templates"), name, []byte(text))
+	}
+
+	valuesData, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChart(objs, templates, valuesData); err != nil {
+		return nil, err
+	}
+	chart, err := yaml.Marshal(chartMetadata{
+		APIVersion:  "v2",
+		Name:        chartName,
+		Description: fmt.Sprintf("Objects of the %s namespace extracted by GitOps Primer", namespace),
+		Type:        "application",
+		Version:     "0.1.0",
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree.Add(helmChart, "Chart.yaml", chart)
+	tree.Add(helmChart, "values.yaml", valuesData)
+	return tree, nil
+}
+
+// helmTemplate turns an object into a template, storing the fields selected
+// by the rules in values
+func helmTemplate(obj *unstructured.Unstructured, rules []valueRule, values map[string]interface{}) (string, error) {
+	tmpl := obj.DeepCopy()
+	key := strings.TrimSuffix(resourceFileName(obj), ".yaml")
+	expressions := map[string]string{}
+	for _, rule := range rules {
+		if len(rule.Kinds) > 0 && !containsString(rule.Kinds, obj.GetKind()) {
+			continue
+		}
+		for i, ref := range rule.path.find(tmpl.Object) {
+			value := ref.get()
+			var expr string
+			switch {
+			case rule.Shared:
+				if existing, ok := values[rule.Name]; ok && !reflect.DeepEqual(existing, value) {
+					continue
+				}
+				values[rule.Name] = value
+				expr = fmt.Sprintf("index .Values %q", rule.Name)
+			case rule.path.hasWildcard():
+				perObject := valueMap(values, rule.Name)
+				list, _ := perObject[key].([]interface{})
+				perObject[key] = append(list, value)
+				expr = fmt.Sprintf("index .Values %q %q %d", rule.Name, key, i)
+			default:
+				valueMap(values, rule.Name)[key] = value
+				expr = fmt.Sprintf("index .Values %q %q", rule.Name, key)
+			}
+			placeholder := fmt.Sprintf("__primer_helm_value_%d__", len(expressions))
+			ref.set(placeholder)
+			expressions[placeholder] = "{{ " + expr + " | toJson }}"
+		}
+	}
+
+	data, err := yaml.Marshal(tmpl.Object)
+	if err != nil {
+		return "", err
+	}
+	text := templateEscaper.Replace(string(data))
+	for placeholder, expr := range expressions {
+		text = strings.Replace(text, placeholder, expr, 1)
+	}
+	return text, nil
+}
+
+// valueMap returns the per object values of a rule
+func valueMap(values map[string]interface{}, name string) map[string]interface{} {
+	m, ok := values[name].(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		values[name] = m
+	}
+	return m
+}
+
+// verifyChart renders every template with the written values and fails
+// unless the result equals the object the template was made from
+func verifyChart(objs []*unstructured.Unstructured, templates map[string]string, valuesData []byte) error {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(valuesData, &values); err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		name := resourceFileName(obj)
+		tmpl, err := template.New(name).Funcs(helmFuncs).Parse(templates[name])
+		if err != nil {
+			return fmt.Errorf("chart template %s: %w", name, err)
+		}
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, map[string]interface{}{"Values": values}); err != nil {
+			return fmt.Errorf("chart template %s: %w", name, err)
+		}
+		var got, want interface{}
+		if err := yaml.Unmarshal(rendered.Bytes(), &got); err != nil {
+			return fmt.Errorf("chart template %s renders invalid YAML: %w", name, err)
+		}
+		original, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(original, &want); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("chart template %s does not render back to %s %s", name, obj.GetKind(), obj.GetName())
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
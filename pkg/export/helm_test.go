@@ -0,0 +1,97 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const workloads = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: test
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.19
+      - name: proxy
+        image: envoy:1.17
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: alerts
+  namespace: test
+data:
+  rule: "{{ $labels.instance }} is down"
+`
+
+var _ = Describe("Helm", func() {
+	spec := &primerv1alpha1.ExtractSpec{
+		Output: &primerv1alpha1.ExtractOutput{Format: primerv1alpha1.OutputHelm},
+	}
+
+	It("moves images, replicas and the namespace into values", func() {
+		objs, err := Decode(strings.NewReader(workloads))
+		Expect(err).NotTo(HaveOccurred())
+		tree, err := Run(objs, "test", spec, Keys{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Paths()).To(Equal([]string{
+			"chart/Chart.yaml",
+			"chart/templates/configmap-alerts.yaml",
+			"chart/templates/deployment.apps-web.yaml",
+			"chart/values.yaml",
+		}))
+
+		values := map[string]interface{}{}
+		Expect(yaml.Unmarshal(tree.Files["chart/values.yaml"], &values)).To(Succeed())
+		Expect(values).To(Equal(map[string]interface{}{
+			"images":    map[string]interface{}{"deployment.apps-web": []interface{}{"nginx:1.19", "envoy:1.17"}},
+			"replicas":  map[string]interface{}{"deployment.apps-web": float64(2)},
+			"namespace": "test",
+		}))
+		Expect(string(tree.Files["chart/templates/deployment.apps-web.yaml"])).To(ContainSubstring(
+			`image: {{ index .Values "images" "deployment.apps-web" 1 | toJson }}`))
+		Expect(string(tree.Files["chart/templates/configmap-alerts.yaml"])).To(ContainSubstring(
+			`{{ "{{" }} $labels.instance {{ "}}" }}`))
+	})
+
+	It("rejects invalid value paths", func() {
+		objs, err := Decode(strings.NewReader(workloads))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = Run(objs, "test", &primerv1alpha1.ExtractSpec{
+			Output: &primerv1alpha1.ExtractOutput{
+				Format: primerv1alpha1.OutputHelm,
+				Helm: &primerv1alpha1.HelmOutput{
+					Values: []primerv1alpha1.HelmValueRule{{Name: "ports", Path: "{.spec.ports[?(@.port==80)]}"}},
+				},
+			},
+		}, Keys{})
+		Expect(err).To(MatchError(ContainSubstring("unsupported subscript")))
+	})
+})
@@ -0,0 +1,198 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// kustomizeBase is the directory of the kustomize layout
+const kustomizeBase = "base"
+
+// Render lays out the objects of a namespace in the format selected by output
+func Render(objs []*unstructured.Unstructured, namespace string, output *primerv1alpha1.ExtractOutput) (*Tree, error) {
+	format := primerv1alpha1.OutputFlat
+	if output != nil && output.Format != "" {
+		format = output.Format
+	}
+	switch format {
+	case primerv1alpha1.OutputFlat:
+		return renderFlat(objs, namespace)
+	case primerv1alpha1.OutputKustomize:
+		return renderKustomize(objs, namespace)
+	case primerv1alpha1.OutputHelm:
+		return renderHelm(objs, namespace, output.Helm)
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// renderFlat writes one file per object below resources/<namespace>, named
+// after kind, group, version, namespace and name like crane export does
+func renderFlat(objs []*unstructured.Unstructured, namespace string) (*Tree, error) {
+	dir := path.Join("resources", namespace)
+	tree := NewTree()
+	tree.Owned = []string{dir}
+	tree.Root = dir
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		gvk := obj.GroupVersionKind()
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = "clusterscoped"
+		}
+		parts := []string{gvk.Kind}
+		if gvk.Group != "" {
+			parts = append(parts, gvk.Group)
+		}
+		parts = append(parts, gvk.Version, ns, obj.GetName())
+		tree.Add(dir, fileName(strings.Join(parts, "_")), data)
+	}
+	return tree, nil
+}
+
+// kustomization is the subset of the kustomize configuration written by the
+// kustomize layout
+type kustomization struct {
+	APIVersion         string                   `json:"apiVersion"`
+	Kind               string                   `json:"kind"`
+	Namespace          string                   `json:"namespace,omitempty"`
+	Resources          []string                 `json:"resources,omitempty"`
+	ConfigMapGenerator []configMapGeneratorArgs `json:"configMapGenerator,omitempty"`
+}
+
+type configMapGeneratorArgs struct {
+	Name    string            `json:"name"`
+	Files   []string          `json:"files,omitempty"`
+	Options *generatorOptions `json:"options,omitempty"`
+}
+
+type generatorOptions struct {
+	Labels                map[string]string `json:"labels,omitempty"`
+	Annotations           map[string]string `json:"annotations,omitempty"`
+	DisableNameSuffixHash bool              `json:"disableNameSuffixHash,omitempty"`
+}
+
+// renderKustomize writes a kustomize base listing every object. ConfigMaps
+// are turned into configMapGenerator entries with one file per key.
+func renderKustomize(objs []*unstructured.Unstructured, namespace string) (*Tree, error) {
+	tree := NewTree()
+	tree.Owned = []string{kustomizeBase}
+	tree.Root = kustomizeBase
+	k := kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Namespace:  namespace,
+	}
+
+	for _, obj := range objs {
+		if obj.GetAPIVersion() == "v1" && obj.GetKind() == "ConfigMap" {
+			generator, err := configMapGenerator(tree, obj)
+			if err != nil {
+				return nil, err
+			}
+			k.ConfigMapGenerator = append(k.ConfigMapGenerator, generator)
+			continue
+		}
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		name := resourceFileName(obj)
+		tree.Add(kustomizeBase, name, data)
+		k.Resources = append(k.Resources, name)
+	}
+
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return nil, err
+	}
+	tree.Add(kustomizeBase, "kustomization.yaml", data)
+	return tree, nil
+}
+
+// configMapGenerator stores the keys of a ConfigMap as files below
+// configmaps/<name> and returns the generator producing the same ConfigMap
+func configMapGenerator(tree *Tree, obj *unstructured.Unstructured) (configMapGeneratorArgs, error) {
+	dir := path.Join("configmaps", safeName(obj.GetName()))
+	files := map[string][]byte{}
+	data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+	for key, value := range data {
+		files[key] = []byte(value)
+	}
+	binaryData, _, _ := unstructured.NestedStringMap(obj.Object, "binaryData")
+	for key, value := range binaryData {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return configMapGeneratorArgs{}, fmt.Errorf("ConfigMap %s key %s: %w", obj.GetName(), key, err)
+		}
+		files[key] = decoded
+	}
+
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	generator := configMapGeneratorArgs{
+		Name: obj.GetName(),
+		Options: &generatorOptions{
+			Labels:      obj.GetLabels(),
+			Annotations: obj.GetAnnotations(),
+			// Keep the name so that workloads referencing it still match
+			DisableNameSuffixHash: true,
+		},
+	}
+	for _, key := range keys {
+		tree.Add(path.Join(kustomizeBase, dir), key, files[key])
+		generator.Files = append(generator.Files, path.Join(dir, key))
+	}
+	return generator, nil
+}
+
+// resourceFileName names an object's file after its kind, group and name
+func resourceFileName(obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind += "." + gvk.Group
+	}
+	return fileName(kind + "-" + obj.GetName())
+}
+
+// fileName turns a name into a portable YAML file name
+func fileName(name string) string {
+	return safeName(name) + ".yaml"
+}
+
+// safeName replaces the characters of object names that are not portable in
+// file names
+func safeName(name string) string {
+	return strings.NewReplacer(":", "_", "/", "_").Replace(name)
+}
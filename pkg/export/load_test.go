@@ -0,0 +1,72 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load", func() {
+	// archive returns a gzipped tarball laid out like a must-gather dump
+	archive := func() *bytes.Buffer {
+		files := map[string]string{
+			"must-gather/namespaces/test/apps/deployments.yaml": namespaceDump,
+			"must-gather/namespaces/other/core/services.yaml": `
+apiVersion: v1
+kind: Service
+metadata:
+  name: db
+  namespace: other
+`,
+			"must-gather/namespaces/test/test.yaml": `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+`,
+			"must-gather/namespaces/test/pods/web/web/logs/current.log": "not a manifest",
+		}
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gz)
+		for name, content := range files {
+			Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})).To(Succeed())
+			_, err := tw.Write([]byte(content))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+		return buf
+	}
+
+	It("reads the manifests of gzipped tarballs", func() {
+		objs, err := LoadArchive(archive())
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, obj := range InNamespace(objs, "test") {
+			names = append(names, obj.GetKind()+"/"+obj.GetName())
+		}
+		Expect(names).To(Equal([]string{
+			"ConfigMap/kube-root-ca.crt", "ConfigMap/settings", "Service/web", "Deployment/web", "ReplicaSet/web-5d4f8",
+		}))
+	})
+})
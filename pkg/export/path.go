@@ -0,0 +1,74 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// PathVars are the values available to the path template of an Extract
+type PathVars struct {
+	Namespace   string
+	Name        string
+	ClusterName string
+}
+
+// RenderPath expands the path template of the spec
+func RenderPath(spec *primerv1alpha1.ExtractSpec, vars PathVars) (string, error) {
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(spec.Path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %q: %w", spec.Path, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("invalid path %q: %w", spec.Path, err)
+	}
+	return buf.String(), nil
+}
+
+// Directory returns the directory of the repository the spec writes to, ""
+// for the root. The path of the spec must already be rendered.
+func Directory(spec *primerv1alpha1.ExtractSpec) (string, error) {
+	if spec.Path == "" {
+		return spec.ClusterDirectory(), nil
+	}
+	return CleanPath(spec.Path)
+}
+
+// CleanPath cleans a slash separated path relative to the repository root and
+// refuses paths escaping it, returning "" for the root itself
+func CleanPath(p string) (string, error) {
+	clean := path.Clean(p)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("path %q escapes the repository root", p)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// within reports whether the cleaned path p is dir or below it
+func within(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
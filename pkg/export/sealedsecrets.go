@@ -0,0 +1,151 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// parseSealingCertificate returns the public key of a PEM encoded sealed
+// secrets controller certificate
+func parseSealingCertificate(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("certificate does not hold an RSA public key")
+	}
+	return key, nil
+}
+
+// sealSecret returns the SealedSecret kubeseal would create for a Secret
+func sealSecret(secret *unstructured.Unstructured, key *rsa.PublicKey, scope primerv1alpha1.SealedSecretsScope) (*unstructured.Unstructured, error) {
+	var label []byte
+	annotations := map[string]interface{}{}
+	switch scope {
+	case primerv1alpha1.SealedSecretsNamespaceWide:
+		label = []byte(secret.GetNamespace())
+		annotations["sealedsecrets.bitnami.com/namespace-wide"] = "true"
+	case primerv1alpha1.SealedSecretsClusterWide:
+		annotations["sealedsecrets.bitnami.com/cluster-wide"] = "true"
+	default:
+		label = []byte(secret.GetNamespace() + "/" + secret.GetName())
+	}
+
+	values := map[string][]byte{}
+	data, _, _ := unstructured.NestedStringMap(secret.Object, "data")
+	for k, v := range data {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("Secret %s key %s: %w", secret.GetName(), k, err)
+		}
+		values[k] = decoded
+	}
+	stringData, _, _ := unstructured.NestedStringMap(secret.Object, "stringData")
+	for k, v := range stringData {
+		values[k] = []byte(v)
+	}
+	encryptedData := map[string]interface{}{}
+	for k, v := range values {
+		sealed, err := hybridEncrypt(key, v, label)
+		if err != nil {
+			return nil, err
+		}
+		encryptedData[k] = base64.StdEncoding.EncodeToString(sealed)
+	}
+
+	templateMeta := map[string]interface{}{
+		"name":      secret.GetName(),
+		"namespace": secret.GetNamespace(),
+	}
+	if labels, ok, _ := unstructured.NestedMap(secret.Object, "metadata", "labels"); ok {
+		templateMeta["labels"] = labels
+	}
+	if secretAnnotations, ok, _ := unstructured.NestedMap(secret.Object, "metadata", "annotations"); ok {
+		templateMeta["annotations"] = secretAnnotations
+	}
+	template := map[string]interface{}{"metadata": templateMeta}
+	if secretType, ok, _ := unstructured.NestedString(secret.Object, "type"); ok {
+		template["type"] = secretType
+	}
+	if immutable, ok, _ := unstructured.NestedBool(secret.Object, "immutable"); ok {
+		template["immutable"] = immutable
+	}
+
+	metadata := map[string]interface{}{
+		"name":      secret.GetName(),
+		"namespace": secret.GetNamespace(),
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"encryptedData": encryptedData,
+			"template":      template,
+		},
+	}}, nil
+}
+
+// hybridEncrypt encrypts plaintext like the sealed secrets controller
+// expects: a random AES-256-GCM session key is encrypted with RSA-OAEP using
+// label, prefixed with its length and followed by the sealed plaintext
+func hybridEncrypt(key *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, 2, 2+len(encryptedKey)+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(encryptedKey)))
+	ciphertext = append(ciphertext, encryptedKey...)
+	// The session key is used once, so a zero nonce is safe
+	return aead.Seal(ciphertext, make([]byte, aead.NonceSize()), plaintext, nil), nil
+}
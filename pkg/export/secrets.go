@@ -0,0 +1,118 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// Keys holds the key material used by the secret policies. It is mounted
+// into the extraction rather than being part of the spec.
+type Keys struct {
+	// AgeRecipients are the age public keys used by the SOPS policy, one per line
+	AgeRecipients []byte
+	// SealingCertificate is the PEM encoded certificate used by the
+	// SealedSecrets policy
+	SealingCertificate []byte
+}
+
+// ProtectSecrets applies the secret policy to the objects. Secrets are
+// dropped, redacted, encrypted or replaced by SealedSecrets or
+// ExternalSecrets, the returned objects never hold plaintext secret data.
+func ProtectSecrets(objs []*unstructured.Unstructured, secrets *primerv1alpha1.SecretsSpec, format primerv1alpha1.OutputFormat, keys Keys) ([]*unstructured.Unstructured, error) {
+	policy := primerv1alpha1.SecretPolicyExclude
+	if secrets != nil && secrets.Policy != "" {
+		policy = secrets.Policy
+	}
+
+	var protect func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	switch policy {
+	case primerv1alpha1.SecretPolicyExclude:
+		protect = func(*unstructured.Unstructured) (*unstructured.Unstructured, error) {
+			return nil, nil
+		}
+	case primerv1alpha1.SecretPolicyRedact:
+		protect = func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+			redact(obj, "data")
+			redact(obj, "stringData")
+			return obj, nil
+		}
+	case primerv1alpha1.SecretPolicySOPS:
+		if format == primerv1alpha1.OutputHelm {
+			return nil, fmt.Errorf("the SOPS secret policy cannot be used with the helm format")
+		}
+		recipients, err := parseAgeRecipients(keys.AgeRecipients)
+		if err != nil {
+			return nil, err
+		}
+		protect = func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+			return obj, sopsEncrypt(obj, recipients)
+		}
+	case primerv1alpha1.SecretPolicySealedSecrets:
+		key, err := parseSealingCertificate(keys.SealingCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("sealed secrets certificate: %w", err)
+		}
+		scope := primerv1alpha1.SealedSecretsStrict
+		if secrets.SealedSecrets != nil && secrets.SealedSecrets.Scope != "" {
+			scope = secrets.SealedSecrets.Scope
+		}
+		protect = func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+			return sealSecret(obj, key, scope)
+		}
+	case primerv1alpha1.SecretPolicyExternalSecret:
+		converter, err := newExternalSecretConverter(secrets.ExternalSecret)
+		if err != nil {
+			return nil, err
+		}
+		protect = converter.convert
+	default:
+		return nil, fmt.Errorf("unknown secret policy %q", policy)
+	}
+
+	kept := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if obj.GetAPIVersion() != "v1" || obj.GetKind() != "Secret" {
+			kept = append(kept, obj)
+			continue
+		}
+		protected, err := protect(obj)
+		if err != nil {
+			return nil, fmt.Errorf("Secret %s: %w", obj.GetName(), err)
+		}
+		if protected != nil {
+			kept = append(kept, protected)
+		}
+	}
+	return kept, nil
+}
+
+// redact blanks the values of a Secret field, keeping its keys
+func redact(obj *unstructured.Unstructured, field string) {
+	values, ok, _ := unstructured.NestedMap(obj.Object, field)
+	if !ok {
+		return
+	}
+	for key := range values {
+		values[key] = ""
+	}
+	unstructured.SetNestedMap(obj.Object, values, field)
+}
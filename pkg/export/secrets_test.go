@@ -0,0 +1,245 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	sopsage "go.mozilla.org/sops/v3/age"
+	"go.mozilla.org/sops/v3/decrypt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+const secretDump = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: test
+  labels:
+    app: web
+data:
+  password: aHVudGVyMg==
+stringData:
+  user: admin
+type: Opaque
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: test
+data:
+  a.properties: a=1
+`
+
+const testAgeRecipient = "age1xfj58gkwuxrujktgn2lup6hktpaffuu5xx0dq7fpqvazu3v49cls93dun3"
+
+var _ = Describe("Secrets", func() {
+	var objs []*unstructured.Unstructured
+
+	BeforeEach(func() {
+		var err error
+		objs, err = Decode(strings.NewReader(secretDump))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	protect := func(secrets *primerv1alpha1.SecretsSpec, keys Keys) []*unstructured.Unstructured {
+		protected, err := ProtectSecrets(objs, secrets, primerv1alpha1.OutputFlat, keys)
+		Expect(err).NotTo(HaveOccurred())
+		return protected
+	}
+
+	It("excludes Secrets by default", func() {
+		protected := protect(nil, Keys{})
+		Expect(protected).To(HaveLen(1))
+		Expect(protected[0].GetKind()).To(Equal("ConfigMap"))
+	})
+
+	It("keeps the keys of redacted Secrets", func() {
+		protected := protect(&primerv1alpha1.SecretsSpec{Policy: primerv1alpha1.SecretPolicyRedact}, Keys{})
+		Expect(protected[0].Object["data"]).To(Equal(map[string]interface{}{"password": ""}))
+		Expect(protected[0].Object["stringData"]).To(Equal(map[string]interface{}{"user": ""}))
+	})
+
+	It("encrypts the values of Secrets with SOPS", func() {
+		protected := protect(&primerv1alpha1.SecretsSpec{Policy: primerv1alpha1.SecretPolicySOPS}, Keys{
+			AgeRecipients: []byte("# team key\n" + testAgeRecipient + "\n"),
+		})
+		secret := protected[0]
+		password, _, _ := unstructured.NestedString(secret.Object, "data", "password")
+		Expect(password).To(HavePrefix("ENC[AES256_GCM,data:"))
+		Expect(password).To(HaveSuffix(",type:str]"))
+		user, _, _ := unstructured.NestedString(secret.Object, "stringData", "user")
+		Expect(user).To(HavePrefix("ENC[AES256_GCM,data:"))
+		Expect(secret.GetLabels()).To(Equal(map[string]string{"app": "web"}))
+
+		recipients, _, _ := unstructured.NestedSlice(secret.Object, "sops", "age")
+		Expect(recipients).To(HaveLen(1))
+		Expect(recipients[0]).To(HaveKeyWithValue("recipient", testAgeRecipient))
+		Expect(recipients[0].(map[string]interface{})["enc"]).To(HavePrefix("-----BEGIN AGE ENCRYPTED FILE-----\n"))
+		mac, _, _ := unstructured.NestedString(secret.Object, "sops", "mac")
+		Expect(mac).To(HavePrefix("ENC[AES256_GCM,data:"))
+	})
+
+	It("writes SOPS files the sops library decrypts and authenticates", func() {
+		identity, err := age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		os.Setenv(sopsage.SopsAgeKeyEnv, identity.String())
+		defer os.Unsetenv(sopsage.SopsAgeKeyEnv)
+
+		spec := &primerv1alpha1.ExtractSpec{Secrets: &primerv1alpha1.SecretsSpec{Policy: primerv1alpha1.SecretPolicySOPS}}
+		tree, err := Run(objs, "test", spec, Keys{AgeRecipients: []byte(identity.Recipient().String())})
+		Expect(err).NotTo(HaveOccurred())
+		var file []byte
+		for p, data := range tree.Files {
+			if strings.Contains(p, "Secret") {
+				file = data
+			}
+		}
+		Expect(file).NotTo(BeNil())
+
+		plain, err := decrypt.Data(file, "yaml")
+		Expect(err).NotTo(HaveOccurred())
+		secret := map[string]interface{}{}
+		Expect(yaml.Unmarshal(plain, &secret)).To(Succeed())
+		Expect(secret["data"]).To(Equal(map[string]interface{}{"password": "aHVudGVyMg=="}))
+		Expect(secret["stringData"]).To(Equal(map[string]interface{}{"user": "admin"}))
+
+		// Dropped values no longer match the MAC
+		encrypted := map[string]interface{}{}
+		Expect(yaml.Unmarshal(file, &encrypted)).To(Succeed())
+		delete(encrypted, "stringData")
+		tampered, err := yaml.Marshal(encrypted)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = decrypt.Data(tampered, "yaml")
+		Expect(err).To(MatchError(ContainSubstring("Failed to verify data integrity")))
+	})
+
+	It("requires age recipients and a format SOPS can decrypt", func() {
+		_, err := ProtectSecrets(objs, &primerv1alpha1.SecretsSpec{Policy: primerv1alpha1.SecretPolicySOPS}, primerv1alpha1.OutputFlat, Keys{})
+		Expect(err).To(MatchError("no age recipients"))
+		_, err = ProtectSecrets(objs, &primerv1alpha1.SecretsSpec{Policy: primerv1alpha1.SecretPolicySOPS}, primerv1alpha1.OutputHelm, Keys{
+			AgeRecipients: []byte(testAgeRecipient),
+		})
+		Expect(err).To(HaveOccurred())
+		_, err = parseAgeRecipients([]byte(testAgeRecipient[:len(testAgeRecipient)-1] + "q"))
+		Expect(err).To(MatchError(ContainSubstring("invalid checksum")))
+	})
+
+	It("replaces Secrets by ExternalSecrets without their data", func() {
+		protected := protect(&primerv1alpha1.SecretsSpec{
+			Policy: primerv1alpha1.SecretPolicyExternalSecret,
+			ExternalSecret: &primerv1alpha1.ExternalSecretSpec{
+				SecretStoreRef: primerv1alpha1.ExternalSecretStoreRef{Name: "vault", Kind: "ClusterSecretStore"},
+				KeyTemplate:    "apps/{{ .Namespace }}/{{ .Name }}",
+			},
+		}, Keys{})
+		external := protected[0]
+		Expect(external.GetAPIVersion()).To(Equal("external-secrets.io/v1alpha1"))
+		Expect(external.GetKind()).To(Equal("ExternalSecret"))
+		Expect(external.Object["spec"]).To(Equal(map[string]interface{}{
+			"refreshInterval": "1h0m0s",
+			"secretStoreRef":  map[string]interface{}{"name": "vault", "kind": "ClusterSecretStore"},
+			"target": map[string]interface{}{
+				"name":           "db",
+				"creationPolicy": "Owner",
+				"template": map[string]interface{}{
+					"type":     "Opaque",
+					"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+				},
+			},
+			"data": []interface{}{
+				map[string]interface{}{
+					"secretKey": "password",
+					"remoteRef": map[string]interface{}{"key": "apps/test/db", "property": "password"},
+				},
+				map[string]interface{}{
+					"secretKey": "user",
+					"remoteRef": map[string]interface{}{"key": "apps/test/db", "property": "user"},
+				},
+			},
+		}))
+
+		_, err := ProtectSecrets(objs, &primerv1alpha1.SecretsSpec{Policy: primerv1alpha1.SecretPolicyExternalSecret}, primerv1alpha1.OutputFlat, Keys{})
+		Expect(err).To(MatchError(ContainSubstring("requires a secretStoreRef")))
+	})
+
+	It("replaces Secrets by SealedSecrets", func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "sealed-secrets"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).NotTo(HaveOccurred())
+
+		protected := protect(&primerv1alpha1.SecretsSpec{Policy: primerv1alpha1.SecretPolicySealedSecrets}, Keys{
+			SealingCertificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}),
+		})
+		sealed := protected[0]
+		Expect(sealed.GetKind()).To(Equal("SealedSecret"))
+		Expect(sealed.GetName()).To(Equal("db"))
+		secretTemplate, _, _ := unstructured.NestedMap(sealed.Object, "spec", "template")
+		Expect(secretTemplate).To(Equal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "db",
+				"namespace": "test",
+				"labels":    map[string]interface{}{"app": "web"},
+			},
+			"type": "Opaque",
+		}))
+
+		// Decrypt like the sealed secrets controller does
+		encrypted, _, _ := unstructured.NestedStringMap(sealed.Object, "spec", "encryptedData")
+		Expect(encrypted).To(HaveLen(2))
+		ciphertext, err := base64.StdEncoding.DecodeString(encrypted["password"])
+		Expect(err).NotTo(HaveOccurred())
+		keyLen := int(binary.BigEndian.Uint16(ciphertext))
+		sessionKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, ciphertext[2:2+keyLen], []byte("test/db"))
+		Expect(err).NotTo(HaveOccurred())
+		block, err := aes.NewCipher(sessionKey)
+		Expect(err).NotTo(HaveOccurred())
+		aead, err := cipher.NewGCM(block)
+		Expect(err).NotTo(HaveOccurred())
+		plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[2+keyLen:], nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plaintext)).To(Equal("hunter2"))
+	})
+})
@@ -0,0 +1,103 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mozilla.org/sops/v3"
+	sopsaes "go.mozilla.org/sops/v3/aes"
+	sopsage "go.mozilla.org/sops/v3/age"
+	"go.mozilla.org/sops/v3/keys"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+	"go.mozilla.org/sops/v3/version"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// sopsEncryptedRegex limits the encryption to the values of Secrets
+const sopsEncryptedRegex = "^(data|stringData)$"
+
+// sopsEncrypt encrypts the data and stringData values of a Secret with the
+// SOPS library the way `sops --encrypt --age <recipients> --encrypted-regex
+// '^(data|stringData)$'` does. Every Secret gets its own data key, which is
+// encrypted for every age recipient.
+func sopsEncrypt(obj *unstructured.Unstructured, recipients []string) error {
+	removeNulls(obj.Object)
+	// SOPS computes the MAC over all values in the order they appear in the
+	// file, so the Secret is loaded in the order of the YAML the layouts write
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	store := &sopsyaml.Store{}
+	branches, err := store.LoadPlainFile(data)
+	if err != nil {
+		return err
+	}
+	ageKeys, err := sopsage.MasterKeysFromRecipients(strings.Join(recipients, ","))
+	if err != nil {
+		return err
+	}
+	group := make(sops.KeyGroup, 0, len(ageKeys))
+	for _, key := range ageKeys {
+		group = append(group, keys.MasterKey(key))
+	}
+	tree := sops.Tree{
+		Branches: branches,
+		Metadata: sops.Metadata{
+			KeyGroups:      []sops.KeyGroup{group},
+			EncryptedRegex: sopsEncryptedRegex,
+			Version:        version.Version,
+		},
+	}
+	dataKey, errs := tree.GenerateDataKey()
+	if len(errs) > 0 {
+		return fmt.Errorf("could not encrypt the data key: %v", errs)
+	}
+	cipher := sopsaes.NewCipher()
+	mac, err := tree.Encrypt(dataKey, cipher)
+	if err != nil {
+		return err
+	}
+	tree.Metadata.LastModified = time.Now().UTC()
+	tree.Metadata.MessageAuthenticationCode, err = cipher.Encrypt(mac, dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := store.EmitEncryptedFile(tree)
+	if err != nil {
+		return err
+	}
+	obj.Object = map[string]interface{}{}
+	return yaml.Unmarshal(encrypted, &obj.Object)
+}
+
+// removeNulls drops null fields, SOPS cannot authenticate them
+func removeNulls(m map[string]interface{}) {
+	for key, value := range m {
+		switch v := value.(type) {
+		case nil:
+			delete(m, key)
+		case map[string]interface{}:
+			removeNulls(v)
+		}
+	}
+}
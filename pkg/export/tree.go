@@ -0,0 +1,150 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Tree is the set of files an extraction writes to the repository
+type Tree struct {
+	// Files maps slash separated paths to their content
+	Files map[string][]byte
+	// Owned are the directories that only hold files of the extraction. They
+	// are replaced as a whole so that deleted objects disappear.
+	Owned []string
+	// Root is the directory holding the rendered objects
+	Root string
+	// Objects is the number of rendered objects
+	Objects int
+}
+
+// NewTree returns an empty Tree
+func NewTree() *Tree {
+	return &Tree{Files: map[string][]byte{}}
+}
+
+// Add stores a file below dir
+func (t *Tree) Add(dir, name string, data []byte) {
+	t.Files[path.Join(dir, name)] = data
+}
+
+// Prefix moves all files and directories of the tree below dir
+func (t *Tree) Prefix(dir string) {
+	files := make(map[string][]byte, len(t.Files))
+	for p, data := range t.Files {
+		files[path.Join(dir, p)] = data
+	}
+	t.Files = files
+	for i, owned := range t.Owned {
+		t.Owned[i] = path.Join(dir, owned)
+	}
+	t.Root = path.Join(dir, t.Root)
+}
+
+// Paths returns the paths of all files in lexical order
+func (t *Tree) Paths() []string {
+	paths := make([]string, 0, len(t.Files))
+	for p := range t.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Write replaces the owned directories below dir with the files of the tree.
+// Symlinks in dir, such as those committed to a cloned repository, are never
+// followed.
+func (t *Tree) Write(dir string) error {
+	for _, owned := range t.Owned {
+		// Removing a symlinked directory only removes the link
+		if err := noSymlinks(dir, path.Dir(owned)); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(filepath.Join(dir, filepath.FromSlash(owned))); err != nil {
+			return err
+		}
+	}
+	for _, p := range t.Paths() {
+		if err := noSymlinks(dir, p); err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, t.Files[p], 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noSymlinks fails if the slash separated path p below dir, or any of its
+// parents, exists as symlink
+func noSymlinks(dir, p string) error {
+	current := dir
+	for _, name := range strings.Split(path.Clean(p), "/") {
+		if name == "." {
+			continue
+		}
+		current = filepath.Join(current, name)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write %q through the symlink %q", p, current)
+		}
+	}
+	return nil
+}
+
+// Confine checks that all files of the tree are written below dir and that
+// only directories below dir are pruned
+func (t *Tree) Confine(dir string) error {
+	for p := range t.Files {
+		clean, err := CleanPath(p)
+		if err != nil {
+			return err
+		}
+		if clean == "" || !within(clean, dir) {
+			return fmt.Errorf("file %q is outside of the directory %q", p, dir)
+		}
+	}
+	for _, owned := range t.Owned {
+		clean, err := CleanPath(owned)
+		if err != nil {
+			return err
+		}
+		if clean == "" {
+			return fmt.Errorf("refusing to prune the repository root")
+		}
+		if !within(clean, dir) {
+			return fmt.Errorf("pruned directory %q is outside of the directory %q", owned, dir)
+		}
+	}
+	return nil
+}
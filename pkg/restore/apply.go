@@ -0,0 +1,196 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+// FieldManager owns the fields applied by an Import
+const FieldManager = "gitops-primer"
+
+// resettableMapper is a RESTMapper that can forget what it discovered, so
+// that the kinds of CustomResourceDefinitions applied earlier in the same
+// run are found
+type resettableMapper interface {
+	meta.RESTMapper
+	Reset()
+}
+
+// Applier applies objects with server-side apply
+type Applier struct {
+	Client dynamic.Interface
+	Mapper meta.RESTMapper
+	// Namespace receives the namespaced objects
+	Namespace string
+	// Import is the name of the Import, set as ImportLabel on every object
+	Import string
+}
+
+// Apply applies the objects in order and reports the result of each
+func (a *Applier) Apply(ctx context.Context, objs []*unstructured.Unstructured) []primerv1alpha1.ImportedResource {
+	results := make([]primerv1alpha1.ImportedResource, 0, len(objs))
+	for _, obj := range objs {
+		obj = obj.DeepCopy()
+		resourceClient, err := a.resourceFor(obj)
+		result := resource(obj)
+		if err == nil {
+			result.Result, err = a.apply(ctx, resourceClient, obj)
+		}
+		if err != nil {
+			result.Result = primerv1alpha1.ImportFailed
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Prune deletes the objects applied by a previous run that are no longer
+// applied. Objects that lost the ImportLabel in the meantime are left alone.
+func (a *Applier) Prune(ctx context.Context, previous, current []primerv1alpha1.ImportedResource) []primerv1alpha1.ImportedResource {
+	kept := map[primerv1alpha1.ImportedResource]bool{}
+	for _, r := range current {
+		kept[key(r)] = true
+	}
+	results := []primerv1alpha1.ImportedResource{}
+	for _, r := range previous {
+		if kept[key(r)] || r.Result == primerv1alpha1.ImportFailed || r.Result == primerv1alpha1.ImportPruned {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(r.APIVersion)
+		obj.SetKind(r.Kind)
+		obj.SetName(r.Name)
+		obj.SetNamespace(r.Namespace)
+		result := key(r)
+		err := a.prune(ctx, obj)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			result.Result = primerv1alpha1.ImportFailed
+			result.Message = err.Error()
+		} else {
+			result.Result = primerv1alpha1.ImportPruned
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (a *Applier) prune(ctx context.Context, obj *unstructured.Unstructured) error {
+	resourceClient, err := a.resourceFor(obj)
+	if err != nil {
+		return err
+	}
+	existing, err := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if existing.GetLabels()[primerv1alpha1.ImportLabel] != a.Import {
+		return errors.NewNotFound(schema.GroupResource{}, obj.GetName())
+	}
+	propagation := metav1.DeletePropagationBackground
+	return resourceClient.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+}
+
+// apply server-side applies the object and tells whether it was created,
+// changed or left as it was
+func (a *Applier) apply(ctx context.Context, resourceClient dynamic.ResourceInterface, obj *unstructured.Unstructured) (primerv1alpha1.ImportResult, error) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[primerv1alpha1.ImportLabel] = a.Import
+	obj.SetLabels(labels)
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	force := true
+	applied, err := resourceClient.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case existing == nil:
+		return primerv1alpha1.ImportCreated, nil
+	case existing.GetResourceVersion() == applied.GetResourceVersion():
+		return primerv1alpha1.ImportUnchanged, nil
+	}
+	return primerv1alpha1.ImportConfigured, nil
+}
+
+// resourceFor returns the client of the object's resource and moves
+// namespaced objects to the target namespace. The Namespace object of the
+// extraction becomes the target namespace.
+func (a *Applier) resourceFor(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		if mapper, ok := a.Mapper.(resettableMapper); ok {
+			mapper.Reset()
+			mapping, err = a.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		if gvk.Group == "" && gvk.Kind == "Namespace" {
+			obj.SetName(a.Namespace)
+		}
+		return a.Client.Resource(mapping.Resource), nil
+	}
+	obj.SetNamespace(a.Namespace)
+	return a.Client.Resource(mapping.Resource).Namespace(a.Namespace), nil
+}
+
+// resource returns the reported identity of an object
+func resource(obj *unstructured.Unstructured) primerv1alpha1.ImportedResource {
+	return primerv1alpha1.ImportedResource{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+	}
+}
+
+// key strips the outcome from a result so that results of different runs can
+// be compared
+func key(r primerv1alpha1.ImportedResource) primerv1alpha1.ImportedResource {
+	return primerv1alpha1.ImportedResource{APIVersion: r.APIVersion, Kind: r.Kind, Name: r.Name, Namespace: r.Namespace}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+)
+
+func object(apiVersion, kind, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace("restore")
+	obj.SetLabels(labels)
+	return obj
+}
+
+// applyReaction serves the objects of tracker and stores server-side applied
+// objects, which the fake dynamic client does not support
+func applyReaction(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	objects := k8stesting.ObjectReaction(tracker)
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return objects(action)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &obj.Object); err != nil {
+			return true, nil, err
+		}
+		_, err := tracker.Get(action.GetResource(), action.GetNamespace(), patch.GetName())
+		if errors.IsNotFound(err) {
+			err = tracker.Create(action.GetResource(), obj, action.GetNamespace())
+		} else if err == nil {
+			err = tracker.Update(action.GetResource(), obj, action.GetNamespace())
+		}
+		return true, obj, err
+	}
+}
+
+var _ = Describe("Order", func() {
+	It("applies dependencies first", func() {
+		objs := []*unstructured.Unstructured{
+			object("networking.k8s.io/v1", "Ingress", "web", nil),
+			object("apps/v1", "Deployment", "web", nil),
+			object("v1", "Secret", "tls", nil),
+			object("v1", "ConfigMap", "settings", nil),
+			object("rbac.authorization.k8s.io/v1", "RoleBinding", "web", nil),
+			object("v1", "ServiceAccount", "web", nil),
+			object("apps/v1", "Deployment", "api", nil),
+			object("v1", "Namespace", "test", nil),
+		}
+		Order(objs)
+		names := []string{}
+		for _, obj := range objs {
+			names = append(names, obj.GetKind()+"/"+obj.GetName())
+		}
+		Expect(names).To(Equal([]string{
+			"Namespace/test",
+			"ServiceAccount/web",
+			"RoleBinding/web",
+			"ConfigMap/settings",
+			"Secret/tls",
+			"Deployment/web",
+			"Deployment/api",
+			"Ingress/web",
+		}))
+	})
+})
+
+var _ = Describe("Applier", func() {
+	var applier *Applier
+
+	BeforeEach(func() {
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+		applier = &Applier{
+			Client: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{{Version: "v1", Resource: "configmaps"}: "ConfigMapList"},
+				object("v1", "ConfigMap", "old", map[string]string{primerv1alpha1.ImportLabel: "primer"}),
+				object("v1", "ConfigMap", "adopted", nil),
+				object("v1", "ConfigMap", "kept", map[string]string{primerv1alpha1.ImportLabel: "primer"}),
+			),
+			Mapper:    mapper,
+			Namespace: "restore",
+			Import:    "primer",
+		}
+	})
+
+	configMap := func(name string) primerv1alpha1.ImportedResource {
+		return primerv1alpha1.ImportedResource{APIVersion: "v1", Kind: "ConfigMap", Name: name, Namespace: "restore"}
+	}
+
+	It("prunes objects that left the repository", func() {
+		previous := []primerv1alpha1.ImportedResource{configMap("old"), configMap("adopted"), configMap("kept"), configMap("gone")}
+		for i := range previous {
+			previous[i].Result = primerv1alpha1.ImportCreated
+		}
+		results := applier.Prune(context.Background(), previous, []primerv1alpha1.ImportedResource{configMap("kept")})
+
+		pruned := configMap("old")
+		pruned.Result = primerv1alpha1.ImportPruned
+		Expect(results).To(Equal([]primerv1alpha1.ImportedResource{pruned}))
+		list, err := applier.Client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).
+			Namespace("restore").List(context.Background(), metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+		Expect(names).To(ConsistOf("adopted", "kept"))
+	})
+
+	It("prunes objects removed from the repository between two runs", func() {
+		ctx := context.Background()
+		configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+		scheme := runtime.NewScheme()
+		client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{configMaps: "ConfigMapList"})
+		tracker := k8stesting.NewObjectTracker(scheme, serializer.NewCodecFactory(scheme).UniversalDecoder())
+		client.ReactionChain = nil
+		client.AddReactor("*", "*", applyReaction(tracker))
+		applier.Client = client
+
+		first := applier.Apply(ctx, []*unstructured.Unstructured{
+			object("v1", "ConfigMap", "settings", nil),
+			object("v1", "ConfigMap", "legacy", nil),
+		})
+		Expect(first).To(HaveLen(2))
+		for _, result := range first {
+			Expect(result.Result).To(Equal(primerv1alpha1.ImportCreated))
+		}
+
+		second := applier.Apply(ctx, []*unstructured.Unstructured{object("v1", "ConfigMap", "settings", nil)})
+		second = append(second, applier.Prune(ctx, first, second)...)
+		pruned := configMap("legacy")
+		pruned.Result = primerv1alpha1.ImportPruned
+		Expect(second).To(HaveLen(2))
+		Expect(second[1]).To(Equal(pruned))
+
+		list, err := client.Resource(configMaps).Namespace("restore").List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].GetName()).To(Equal("settings"))
+	})
+
+	It("reports objects of unknown kinds", func() {
+		results := applier.Apply(context.Background(), []*unstructured.Unstructured{
+			object("example.com/v1", "Widget", "web", nil),
+		})
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Result).To(Equal(primerv1alpha1.ImportFailed))
+		Expect(results[0].Message).To(ContainSubstring("no matches for kind"))
+	})
+})
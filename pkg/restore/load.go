@@ -0,0 +1,142 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package restore applies the objects of an extraction back to a cluster.
+package restore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// kustomization is the part of a kustomize base written by the kustomize
+// format that is needed to read it back
+type kustomization struct {
+	Resources          []string `json:"resources"`
+	ConfigMapGenerator []struct {
+		Name    string   `json:"name"`
+		Files   []string `json:"files"`
+		Options *struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"options"`
+	} `json:"configMapGenerator"`
+}
+
+// Load reads the objects of an extraction from the slash separated path dir
+// of the repository cloned to root. Directories holding a kustomization.yaml
+// are read as kustomize base, with the ConfigMaps built from their
+// generators, any other directory as plain manifests. Helm charts are not
+// supported. Paths leaving the repository, also through symlinks, are
+// refused.
+func Load(root, dir string) ([]*unstructured.Unstructured, error) {
+	base, err := resolve(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(base, "Chart.yaml")); err == nil {
+		return nil, fmt.Errorf("%s is a Helm chart, install it with helm instead", dir)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(base, "kustomization.yaml"))
+	if os.IsNotExist(err) {
+		return export.Load(base)
+	} else if err != nil {
+		return nil, err
+	}
+	k := kustomization{}
+	if err := yaml.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("%s: %w", path.Join(dir, "kustomization.yaml"), err)
+	}
+
+	objs := []*unstructured.Unstructured{}
+	for _, resource := range k.Resources {
+		resourcePath, err := resolve(root, path.Join(dir, resource))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := export.Load(resourcePath)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, decoded...)
+	}
+	for _, generator := range k.ConfigMapGenerator {
+		cm := &unstructured.Unstructured{}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetName(generator.Name)
+		if generator.Options != nil {
+			cm.SetLabels(generator.Options.Labels)
+			cm.SetAnnotations(generator.Options.Annotations)
+		}
+		for _, file := range generator.Files {
+			filePath, err := resolve(root, path.Join(dir, file))
+			if err != nil {
+				return nil, err
+			}
+			content, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return nil, err
+			}
+			// Binary keys are stored base64 encoded, as the API expects
+			if utf8.Valid(content) {
+				err = unstructured.SetNestedField(cm.Object, string(content), "data", path.Base(file))
+			} else {
+				err = unstructured.SetNestedField(cm.Object, base64.StdEncoding.EncodeToString(content), "binaryData", path.Base(file))
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		objs = append(objs, cm)
+	}
+	export.Sort(objs)
+	return objs, nil
+}
+
+// resolve returns the file path of the slash separated path p of the
+// repository cloned to root, following symlinks as long as they stay in the
+// repository
+func resolve(root, p string) (string, error) {
+	clean, err := export.CleanPath(p)
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(realRoot, filepath.FromSlash(clean)))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(realRoot, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes the repository root", p)
+	}
+	return real, nil
+}
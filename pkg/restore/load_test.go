@@ -0,0 +1,128 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	primerv1alpha1 "github.com/cooktheryan/gitops-primer/api/v1alpha1"
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+const workloads = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: test
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: test
+  labels:
+    app: web
+data:
+  settings.yaml: "debug: true\n"
+binaryData:
+  logo.png: iVBORw0KGgo=
+`
+
+var _ = Describe("Load", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "primer-restore")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	write := func(format primerv1alpha1.OutputFormat) string {
+		objs, err := export.Decode(strings.NewReader(workloads))
+		Expect(err).NotTo(HaveOccurred())
+		tree, err := export.Run(objs, "test", &primerv1alpha1.ExtractSpec{
+			Output: &primerv1alpha1.ExtractOutput{Format: format},
+		}, export.Keys{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Write(dir)).To(Succeed())
+		return tree.Root
+	}
+
+	It("reads the flat format", func() {
+		objs, err := Load(dir, write(primerv1alpha1.OutputFlat))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		Expect(objs[0].GetKind()).To(Equal("ConfigMap"))
+		Expect(objs[1].GetKind()).To(Equal("Deployment"))
+	})
+
+	It("builds the ConfigMaps of a kustomize base from its generators", func() {
+		objs, err := Load(dir, write(primerv1alpha1.OutputKustomize))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+
+		cm := objs[0]
+		Expect(cm.GetKind()).To(Equal("ConfigMap"))
+		Expect(cm.GetName()).To(Equal("settings"))
+		Expect(cm.GetLabels()).To(Equal(map[string]string{"app": "web"}))
+		Expect(cm.Object["data"]).To(Equal(map[string]interface{}{"settings.yaml": "debug: true\n"}))
+		Expect(cm.Object["binaryData"]).To(Equal(map[string]interface{}{"logo.png": "iVBORw0KGgo="}))
+		Expect(objs[1].GetKind()).To(Equal("Deployment"))
+	})
+
+	It("rejects Helm charts", func() {
+		_, err := Load(dir, write(primerv1alpha1.OutputHelm))
+		Expect(err).To(MatchError(ContainSubstring("install it with helm")))
+	})
+
+	It("refuses paths leaving the repository", func() {
+		base := write(primerv1alpha1.OutputKustomize)
+		_, err := Load(dir, "../"+filepath.Base(dir))
+		Expect(err).To(MatchError(ContainSubstring("escapes the repository root")))
+
+		outside, err := ioutil.TempDir("", "primer-outside")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outside)
+		Expect(ioutil.WriteFile(filepath.Join(outside, "token"), []byte("secret"), 0600)).To(Succeed())
+		kustomization := filepath.Join(dir, filepath.FromSlash(base), "kustomization.yaml")
+
+		// Through a resource of the kustomization
+		Expect(ioutil.WriteFile(kustomization, []byte("resources:\n- ../../../../token\n"), 0600)).To(Succeed())
+		_, err = Load(dir, base)
+		Expect(err).To(MatchError(ContainSubstring("escapes the repository root")))
+
+		// Through a symlink committed to the repository
+		Expect(os.Symlink(filepath.Join(outside, "token"), filepath.Join(dir, "token"))).To(Succeed())
+		Expect(ioutil.WriteFile(kustomization, []byte("configMapGenerator:\n- name: stolen\n  files:\n  - "+
+			strings.Repeat("../", strings.Count(base, "/")+1)+"token\n"), 0600)).To(Succeed())
+		_, err = Load(dir, base)
+		Expect(err).To(MatchError(ContainSubstring("escapes the repository root")))
+	})
+})
@@ -0,0 +1,65 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// kindOrder lists kinds in the order they are applied, so that everything an
+// object depends on exists before it. Other kinds, such as Services,
+// Ingresses and custom resources, follow the workloads.
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"ServiceAccount",
+	"ClusterRole",
+	"Role",
+	"ClusterRoleBinding",
+	"RoleBinding",
+	"ConfigMap",
+	"Secret",
+	"SealedSecret",
+	"ExternalSecret",
+	"PersistentVolumeClaim",
+	"Deployment",
+	"StatefulSet",
+	"DaemonSet",
+	"ReplicaSet",
+	"Job",
+	"CronJob",
+	"Pod",
+}
+
+// Order sorts objects by dependency. Objects of the same kind keep their
+// order.
+func Order(objs []*unstructured.Unstructured) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return rank(objs[i]) < rank(objs[j])
+	})
+}
+
+func rank(obj *unstructured.Unstructured) int {
+	for i, kind := range kindOrder {
+		if obj.GetKind() == kind {
+			return i
+		}
+	}
+	return len(kindOrder)
+}
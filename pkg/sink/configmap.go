@@ -0,0 +1,66 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// ConfigMapKey is the binaryData key the configmap sink stores the tarball
+// under
+const ConfigMapKey = "extract.tar.gz"
+
+// ConfigMap stores the files as gzipped tarball in an existing ConfigMap.
+// The controller creates the ConfigMap so that it is owned by the Extract.
+type ConfigMap struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+// Store replaces the tarball held by the ConfigMap
+func (c *ConfigMap) Store(ctx context.Context, tree *export.Tree) (*Result, error) {
+	data, err := tarball(tree)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > corev1.MaxSecretSize {
+		return nil, fmt.Errorf("the extraction takes %d bytes compressed, a ConfigMap holds at most %d bytes, use the pvc sink instead", len(data), corev1.MaxSecretSize)
+	}
+	result := &Result{Location: "configmap/" + c.Namespace + "/" + c.Name}
+	cm, err := c.Client.CoreV1().ConfigMaps(c.Namespace).Get(ctx, c.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(cm.BinaryData[ConfigMapKey], data) {
+		return result, nil
+	}
+	cm.BinaryData = map[string][]byte{ConfigMapKey: data}
+	if _, err := c.Client.CoreV1().ConfigMaps(c.Namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+	result.Changed = true
+	return result, nil
+}
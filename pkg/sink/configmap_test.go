@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+var _ = Describe("ConfigMap", func() {
+	var (
+		client *fake.Clientset
+		c      *ConfigMap
+	)
+
+	BeforeEach(func() {
+		client = fake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "primer-extract-primer-output", Namespace: "test"},
+		})
+		c = &ConfigMap{Client: client, Namespace: "test", Name: "primer-extract-primer-output"}
+	})
+
+	stored := func() []string {
+		cm, err := client.CoreV1().ConfigMaps("test").Get(context.Background(), c.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		gz, err := gzip.NewReader(bytes.NewReader(cm.BinaryData[ConfigMapKey]))
+		Expect(err).NotTo(HaveOccurred())
+		tr := tar.NewReader(gz)
+		var names []string
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return names
+			}
+			Expect(err).NotTo(HaveOccurred())
+			names = append(names, header.Name)
+		}
+	}
+
+	It("stores the tarball and detects unchanged extractions", func() {
+		tree := export.NewTree()
+		tree.Add("resources/test", "configmap-web.yaml", []byte("kind: ConfigMap\n"))
+
+		result, err := c.Store(context.Background(), tree)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(&Result{Location: "configmap/test/primer-extract-primer-output", Changed: true}))
+		Expect(stored()).To(Equal([]string{"resources/test/configmap-web.yaml"}))
+
+		result, err = c.Store(context.Background(), tree)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Changed).To(BeFalse())
+	})
+
+	It("rejects extractions too large for a ConfigMap", func() {
+		tree := export.NewTree()
+		// Random looking content that gzip cannot shrink
+		var data strings.Builder
+		seed := uint32(1)
+		for data.Len() < 2*corev1.MaxSecretSize {
+			seed = seed*1664525 + 1013904223
+			data.WriteByte(byte(seed >> 24))
+		}
+		tree.Add("resources/test", "secret-big.yaml", []byte(data.String()))
+
+		_, err := c.Store(context.Background(), tree)
+		Expect(err).To(MatchError(ContainSubstring("use the pvc sink instead")))
+	})
+})
@@ -0,0 +1,91 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// Directory writes the files to a local directory, such as a mounted
+// PersistentVolumeClaim
+type Directory struct {
+	Dir string
+}
+
+// Store replaces the files of the extraction below the directory
+func (d *Directory) Store(ctx context.Context, tree *export.Tree) (*Result, error) {
+	result := &Result{Location: d.Dir}
+	same, err := d.holds(tree)
+	if err != nil {
+		return nil, err
+	}
+	if same {
+		return result, nil
+	}
+	if err := tree.Write(d.Dir); err != nil {
+		return nil, err
+	}
+	result.Changed = true
+	return result, nil
+}
+
+// holds reports whether the directory already holds exactly the files of
+// the tree
+func (d *Directory) holds(tree *export.Tree) (bool, error) {
+	for _, p := range tree.Paths() {
+		data, err := ioutil.ReadFile(filepath.Join(d.Dir, filepath.FromSlash(p)))
+		if os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(data, tree.Files[p]) {
+			return false, nil
+		}
+	}
+	// Files of deleted objects are left in the owned directories
+	for _, owned := range tree.Owned {
+		root := filepath.Join(d.Dir, filepath.FromSlash(owned))
+		stale := false
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(d.Dir, p)
+			if err != nil {
+				return err
+			}
+			if _, ok := tree.Files[filepath.ToSlash(rel)]; !ok {
+				stale = true
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if stale {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+var _ = Describe("Directory", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "primer-directory")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	newTree := func(names ...string) *export.Tree {
+		tree := export.NewTree()
+		tree.Owned = []string{"base"}
+		for _, name := range names {
+			tree.Add("base", name, []byte("kind: ConfigMap\n"))
+		}
+		return tree
+	}
+
+	It("replaces the files and detects unchanged extractions", func() {
+		d := &Directory{Dir: dir}
+		result, err := d.Store(context.Background(), newTree("a.yaml", "b.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(&Result{Location: dir, Changed: true}))
+
+		result, err = d.Store(context.Background(), newTree("a.yaml", "b.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Changed).To(BeFalse())
+
+		result, err = d.Store(context.Background(), newTree("a.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Changed).To(BeTrue())
+		Expect(filepath.Join(dir, "base", "a.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "base", "b.yaml")).NotTo(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,275 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// Git commits the files to a branch of a repository. The git CLI is used so
+// that the SSH configuration of the image applies.
+type Git struct {
+	Repo   string
+	Branch string
+	Email  string
+	// Dir is where the repository is cloned to
+	Dir string
+	// CreateBranch creates Branch when the repository lacks it, from
+	// BaseBranch or as orphan without BaseBranch
+	CreateBranch bool
+	BaseBranch   string
+	// PushAttempts bounds the pushes of an extraction, defaults to 5
+	PushAttempts int
+	// Backoff is the wait before the first retried push, it doubles with
+	// every further retry and defaults to 2s
+	Backoff time.Duration
+	// Tagging tags every pushed commit
+	Tagging *Tagging
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Store clones the repository, replaces the files of the extraction and
+// pushes a commit unless nothing changed. The result is returned along with
+// push errors to report the retries.
+func (g *Git) Store(ctx context.Context, tree *export.Tree) (*Result, error) {
+	if g.Repo == "" || g.Branch == "" {
+		return nil, fmt.Errorf("the git sink requires a repo and a branch")
+	}
+	if _, err := g.git(ctx, "", "clone", "-q", g.Repo, g.Dir); err != nil {
+		return nil, err
+	}
+	if g.Email != "" {
+		if _, err := g.git(ctx, g.Dir, "config", "user.email", g.Email); err != nil {
+			return nil, err
+		}
+	}
+	created, err := g.checkout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Location: g.Repo + "@" + g.Branch, CreatedBranch: created}
+	changed, err := g.commit(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	if created && !changed && g.BaseBranch == "" {
+		// An orphan branch needs a commit to be pushed
+		if _, err := g.git(ctx, g.Dir, "commit", "-q", "--allow-empty", "-m", "bot commit"); err != nil {
+			return nil, err
+		}
+	}
+	result.Changed = changed
+	if changed || created {
+		if err := g.push(ctx, tree, result); err != nil {
+			return result, err
+		}
+		// Retries find nothing to push when the new head holds the files.
+		// The commit landed even when tagging fails, which is reported
+		// along with the result.
+		if g.Tagging != nil && (result.Changed || result.CreatedBranch) {
+			if err := g.tag(ctx, result); err != nil {
+				result.Error = fmt.Sprintf("tagging failed: %v", err)
+			}
+		}
+	}
+	result.Revision, err = g.revision(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkout checks out the branch of the clone. A missing branch is created
+// when CreateBranch is set, in which case true is returned.
+func (g *Git) checkout(ctx context.Context) (bool, error) {
+	if _, err := g.git(ctx, g.Dir, "rev-parse", "-q", "--verify", "refs/remotes/origin/"+g.Branch); err == nil {
+		_, err := g.git(ctx, g.Dir, "checkout", "-q", g.Branch)
+		return false, err
+	}
+	if !g.CreateBranch {
+		return false, fmt.Errorf("the branch %s does not exist in %s, set createBranch to create it", g.Branch, g.Repo)
+	}
+	if g.BaseBranch != "" {
+		_, err := g.git(ctx, g.Dir, "checkout", "-q", "-b", g.Branch, "origin/"+g.BaseBranch)
+		return true, err
+	}
+	if _, err := g.git(ctx, g.Dir, "checkout", "-q", "--orphan", g.Branch); err != nil {
+		return false, err
+	}
+	// The orphan starts without the files of the default branch
+	_, err := g.git(ctx, g.Dir, "rm", "-rfq", "--ignore-unmatch", ".")
+	return true, err
+}
+
+// Commit replaces the files of the extraction in the existing repository at
+// Dir and commits them to its checked out branch without pushing, for
+// extractions run outside of the cluster
+func (g *Git) Commit(ctx context.Context, tree *export.Tree) (*Result, error) {
+	if g.Email != "" {
+		if _, err := g.git(ctx, g.Dir, "config", "user.email", g.Email); err != nil {
+			return nil, err
+		}
+	}
+	changed, err := g.commit(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := g.revision(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Location: g.Dir, Changed: changed, Revision: revision}, nil
+}
+
+// push pushes the commit of the extraction. A rejected push, such as when
+// another extraction or a human pushed to the branch in the meantime, is
+// retried with backoff after fetching the branch and committing the files
+// again on top of its new head.
+func (g *Git) push(ctx context.Context, tree *export.Tree, result *Result) error {
+	attempts := g.PushAttempts
+	if attempts <= 0 {
+		attempts = 5
+	}
+	backoff := g.Backoff
+	if backoff <= 0 {
+		backoff = 2 * time.Second
+	}
+	for {
+		_, err := g.git(ctx, g.Dir, "push", "-q", "origin", g.Branch)
+		if err == nil {
+			return nil
+		}
+		// Only pushes rejected because the branch moved succeed when
+		// retried, authentication, network and hook failures do not
+		if !nonFastForward(err) {
+			return err
+		}
+		if result.PushRetries+1 >= attempts {
+			return fmt.Errorf("giving up after %d pushes: %w", attempts, err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		result.PushRetries++
+
+		if _, fetchErr := g.git(ctx, g.Dir, "fetch", "-q", "origin", g.Branch); fetchErr != nil {
+			return fmt.Errorf("%w, fetching the branch to retry failed: %v", err, fetchErr)
+		}
+		// The branch was created by someone else in the meantime
+		result.CreatedBranch = false
+		if _, err := g.git(ctx, g.Dir, "reset", "-q", "--hard", "FETCH_HEAD"); err != nil {
+			return err
+		}
+		changed, err := g.commit(ctx, tree)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			// The new head already holds the same files
+			result.Changed = false
+			return nil
+		}
+	}
+}
+
+// revision returns the commit checked out in the repository
+func (g *Git) revision(ctx context.Context) (string, error) {
+	out, err := g.git(ctx, g.Dir, "rev-parse", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// commit writes the tree to the repository and commits the changes, if any
+func (g *Git) commit(ctx context.Context, tree *export.Tree) (bool, error) {
+	if err := tree.Write(g.Dir); err != nil {
+		return false, err
+	}
+	if _, err := g.git(ctx, g.Dir, "add", "-A"); err != nil {
+		return false, err
+	}
+	changes, err := g.git(ctx, g.Dir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(changes) == "" {
+		return false, nil
+	}
+	if _, err := g.git(ctx, g.Dir, "commit", "-q", "-m", "bot commit"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// git runs a git command in dir and returns its output
+func (g *Git) git(ctx context.Context, dir string, args ...string) (string, error) {
+	return g.gitEnv(ctx, dir, nil, args...)
+}
+
+// gitEnv runs a git command in dir with additional environment variables
+func (g *Git) gitEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", &gitError{command: args[0], err: err, stderr: strings.TrimSpace(stderr.String())}
+	}
+	return stdout.String(), nil
+}
+
+// gitError is a failed git command with its error output
+type gitError struct {
+	command string
+	err     error
+	stderr  string
+}
+
+func (e *gitError) Error() string {
+	return fmt.Sprintf("git %s: %v: %s", e.command, e.err, e.stderr)
+}
+
+func (e *gitError) Unwrap() error {
+	return e.err
+}
+
+// nonFastForward reports whether err is a push the remote rejected because
+// it does not build on the head of the branch
+func nonFastForward(err error) bool {
+	var gitErr *gitError
+	if !errors.As(err, &gitErr) || gitErr.command != "push" {
+		return false
+	}
+	// Hooks declining the push are reported as [remote rejected]
+	return strings.Contains(gitErr.stderr, "[rejected]") &&
+		(strings.Contains(gitErr.stderr, "(fetch first)") || strings.Contains(gitErr.stderr, "(non-fast-forward)"))
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+var _ = Describe("Git", func() {
+	var (
+		dir  string
+		repo string
+	)
+
+	run := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		return string(out)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "primer-git")
+		Expect(err).NotTo(HaveOccurred())
+		for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+			os.Setenv(env, "primer")
+		}
+
+		// A bare repository with a main branch holding a README
+		repo = filepath.Join(dir, "origin.git")
+		run(dir, "init", "-q", "--bare", repo)
+		seed := filepath.Join(dir, "seed")
+		run(dir, "clone", "-q", repo, seed)
+		Expect(ioutil.WriteFile(filepath.Join(seed, "README.md"), []byte("apps\n"), 0644)).To(Succeed())
+		run(seed, "add", "README.md")
+		run(seed, "-c", "user.email=seed@example.com", "commit", "-q", "-m", "seed")
+		run(seed, "push", "-q", "origin", "HEAD:refs/heads/main")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	store := func(name string, tree *export.Tree) *Result {
+		sink := &Git{Repo: repo, Branch: "main", Email: "primer@example.com", Dir: filepath.Join(dir, name)}
+		result, err := sink.Store(context.Background(), tree)
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	It("pushes a commit only when files changed", func() {
+		tree := export.NewTree()
+		tree.Owned = []string{"resources/test"}
+		tree.Add("resources/test", "Service_v1_test_web.yaml", []byte("kind: Service\n"))
+
+		first := store("first", tree)
+		Expect(first.Changed).To(BeTrue())
+		Expect(first.Revision).To(Equal(strings.TrimSpace(run(dir, "--git-dir", repo, "rev-parse", "main"))))
+		Expect(run(dir, "--git-dir", repo, "log", "--format=%s %ae", "main")).To(Equal("bot commit primer@example.com\nseed seed@example.com\n"))
+		Expect(run(dir, "--git-dir", repo, "show", "main:resources/test/Service_v1_test_web.yaml")).To(Equal("kind: Service\n"))
+
+		Expect(store("second", tree).Changed).To(BeFalse())
+		Expect(run(dir, "--git-dir", repo, "rev-list", "--count", "main")).To(Equal("2\n"))
+	})
+
+	It("commits to a local checkout without pushing", func() {
+		checkout := filepath.Join(dir, "checkout")
+		run(dir, "clone", "-q", "-b", "main", repo, checkout)
+		tree := export.NewTree()
+		tree.Owned = []string{"resources/test"}
+		tree.Add("resources/test", "Service_v1_test_web.yaml", []byte("kind: Service\n"))
+
+		sink := &Git{Email: "primer@example.com", Dir: checkout}
+		result, err := sink.Commit(context.Background(), tree)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Location).To(Equal(checkout))
+		Expect(result.Changed).To(BeTrue())
+		Expect(result.Revision).To(Equal(strings.TrimSpace(run(checkout, "rev-parse", "HEAD"))))
+		Expect(run(checkout, "rev-list", "--count", "HEAD")).To(Equal("2\n"))
+		Expect(run(dir, "--git-dir", repo, "rev-list", "--count", "main")).To(Equal("1\n"))
+	})
+
+	Context("with a missing branch", func() {
+		tree := export.NewTree()
+		tree.Owned = []string{"resources/test"}
+		tree.Add("resources/test", "Service_v1_test_web.yaml", []byte("kind: Service\n"))
+
+		storeTo := func(sink *Git) (*Result, error) {
+			sink.Repo = repo
+			sink.Branch = "stage"
+			sink.Email = "primer@example.com"
+			sink.Dir = filepath.Join(dir, "clone")
+			return sink.Store(context.Background(), tree)
+		}
+
+		It("fails unless the branch may be created", func() {
+			_, err := storeTo(&Git{})
+			Expect(err).To(MatchError(ContainSubstring("set createBranch")))
+		})
+
+		It("creates the branch from the base branch", func() {
+			result, err := storeTo(&Git{CreateBranch: true, BaseBranch: "main"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.CreatedBranch).To(BeTrue())
+			Expect(run(dir, "--git-dir", repo, "log", "--format=%s", "stage")).To(Equal("bot commit\nseed\n"))
+		})
+
+		It("creates an orphan branch without a base branch", func() {
+			result, err := storeTo(&Git{CreateBranch: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.CreatedBranch).To(BeTrue())
+			Expect(run(dir, "--git-dir", repo, "log", "--format=%s", "stage")).To(Equal("bot commit\n"))
+			Expect(run(dir, "--git-dir", repo, "ls-tree", "-r", "--name-only", "stage")).To(Equal("resources/test/Service_v1_test_web.yaml\n"))
+		})
+	})
+
+	Context("when the branch moves before the push", func() {
+		// concurrentPush installs a post-commit hook pushing another commit
+		// to main from the seed clone, once or after every commit of the
+		// sink, so that its next push is rejected as non-fast-forward
+		concurrentPush := func(once bool) {
+			hooks := filepath.Join(dir, "hooks")
+			Expect(os.MkdirAll(hooks, 0755)).To(Succeed())
+			guard := ""
+			if once {
+				guard = "[ -f " + filepath.Join(dir, "pushed") + " ] && exit 0\ntouch " + filepath.Join(dir, "pushed") + "\n"
+			}
+			hook := "#!/bin/sh\n[ -n \"$CONCURRENT\" ] && exit 0\nunset GIT_DIR GIT_WORK_TREE GIT_INDEX_FILE\n" + guard +
+				"seed=" + filepath.Join(dir, "seed") + "\n" +
+				"date +%s%N >> $seed/README.md\n" +
+				"export CONCURRENT=1\n" +
+				"git -C $seed -c user.email=human@example.com commit -q -am concurrent\n" +
+				"git -C $seed push -q origin HEAD:refs/heads/main\n"
+			Expect(ioutil.WriteFile(filepath.Join(hooks, "post-commit"), []byte(hook), 0755)).To(Succeed())
+			os.Setenv("GIT_CONFIG_COUNT", "1")
+			os.Setenv("GIT_CONFIG_KEY_0", "core.hooksPath")
+			os.Setenv("GIT_CONFIG_VALUE_0", hooks)
+		}
+
+		AfterEach(func() {
+			for _, env := range []string{"GIT_CONFIG_COUNT", "GIT_CONFIG_KEY_0", "GIT_CONFIG_VALUE_0"} {
+				os.Unsetenv(env)
+			}
+		})
+
+		tree := func() *export.Tree {
+			tree := export.NewTree()
+			tree.Owned = []string{"resources/test"}
+			tree.Add("resources/test", "Service_v1_test_web.yaml", []byte("kind: Service\n"))
+			return tree
+		}
+
+		It("commits again on top of the new head and retries", func() {
+			concurrentPush(true)
+			sink := &Git{Repo: repo, Branch: "main", Email: "primer@example.com", Dir: filepath.Join(dir, "clone"), Backoff: time.Millisecond}
+			result, err := sink.Store(context.Background(), tree())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Changed).To(BeTrue())
+			Expect(result.PushRetries).To(Equal(1))
+			Expect(run(dir, "--git-dir", repo, "log", "--format=%s", "main")).To(Equal("bot commit\nconcurrent\nseed\n"))
+			Expect(result.Revision).To(Equal(strings.TrimSpace(run(dir, "--git-dir", repo, "rev-parse", "main"))))
+		})
+
+		It("gives up after the configured attempts", func() {
+			concurrentPush(false)
+			sink := &Git{Repo: repo, Branch: "main", Email: "primer@example.com", Dir: filepath.Join(dir, "clone"), PushAttempts: 3, Backoff: time.Millisecond}
+			result, err := sink.Store(context.Background(), tree())
+			Expect(err).To(MatchError(ContainSubstring("giving up after 3 pushes")))
+			Expect(result.PushRetries).To(Equal(2))
+		})
+
+		It("does not retry pushes the remote declines", func() {
+			hook := filepath.Join(repo, "hooks", "pre-receive")
+			Expect(ioutil.WriteFile(hook, []byte("#!/bin/sh\necho protected >&2\nexit 1\n"), 0755)).To(Succeed())
+			sink := &Git{Repo: repo, Branch: "main", Email: "primer@example.com", Dir: filepath.Join(dir, "clone"), Backoff: time.Millisecond}
+			result, err := sink.Store(context.Background(), tree())
+			Expect(err).To(MatchError(ContainSubstring("pre-receive hook declined")))
+			Expect(err).NotTo(MatchError(ContainSubstring("giving up")))
+			Expect(result.PushRetries).To(Equal(0))
+		})
+	})
+
+	Context("with tagging", func() {
+		storeRun := func(runNumber int, content string, tagging Tagging) (*Result, error) {
+			tree := export.NewTree()
+			tree.Owned = []string{"resources/test"}
+			tree.Add("resources/test", "Service_v1_test_web.yaml", []byte(content))
+			tagging.Vars = TagVars{Run: runNumber, Namespace: "test", Name: "primer"}
+			sink := &Git{
+				Repo:    repo,
+				Branch:  "main",
+				Email:   "primer@example.com",
+				Dir:     filepath.Join(dir, "run", strconv.Itoa(runNumber)),
+				Tagging: &tagging,
+				Now: func() time.Time {
+					return time.Date(2021, 6, runNumber, 12, 0, 0, 0, time.UTC)
+				},
+			}
+			return sink.Store(context.Background(), tree)
+		}
+		tags := func() string {
+			return run(dir, "--git-dir", repo, "tag", "-l", "--sort=refname")
+		}
+
+		It("tags pushed commits and deletes tags beyond the retention", func() {
+			// Tags not matching the name template are kept, whatever their
+			// message claims
+			tagSeed := func(name, message string) {
+				run(dir, "--git-dir", repo, "-c", "user.email=seed@example.com", "tag", "-a", "-m", message, name, "main")
+			}
+			tagSeed("other", "Snapshot of other/primer")
+			tagSeed("forged", "Snapshot of test/primer")
+			tagSeed("other-2021-05-01-1", "Snapshot of other/primer")
+			tagging := Tagging{Name: "{{.Namespace}}-{{.Date}}-{{.Run}}", Retention: 2, Owner: "test/primer"}
+			for i := 1; i <= 3; i++ {
+				result, err := storeRun(i, "kind: Service\nrun: "+strconv.Itoa(i)+"\n", tagging)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Error).To(BeEmpty())
+				Expect(result.Tag).To(Equal("test-2021-06-0" + strconv.Itoa(i) + "-" + strconv.Itoa(i)))
+				Expect(strings.TrimSpace(run(dir, "--git-dir", repo, "rev-parse", result.Tag+"^{commit}"))).To(Equal(result.Revision))
+			}
+			Expect(tags()).To(Equal("forged\nother\nother-2021-05-01-1\ntest-2021-06-02-2\ntest-2021-06-03-3\n"))
+			Expect(run(dir, "--git-dir", repo, "tag", "-l", "--format=%(contents:subject)", "test-2021-06-03-3")).To(Equal("Snapshot of test/primer\n"))
+		})
+
+		It("only deletes the tags of its owner when several share the template", func() {
+			run(dir, "--git-dir", repo, "tag", "snapshot-2021-05-01", "main")
+			shop := Tagging{Name: "snapshot-{{.Date}}", Retention: 1, Owner: "shop/primer"}
+			billing := Tagging{Name: "snapshot-{{.Date}}", Retention: 1, Owner: "billing/primer"}
+			for i, tagging := range []Tagging{shop, shop, billing, shop} {
+				result, err := storeRun(i+1, "kind: Service\nrun: "+strconv.Itoa(i)+"\n", tagging)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Error).To(BeEmpty())
+			}
+			// The lightweight tag and the tag of billing survive the retention
+			// of shop
+			Expect(tags()).To(Equal("snapshot-2021-05-01\nsnapshot-2021-06-03\nsnapshot-2021-06-04\n"))
+			Expect(run(dir, "--git-dir", repo, "tag", "-l", "--format=%(contents:subject)", "snapshot-2021-06-03")).To(Equal("Snapshot of billing/primer\n"))
+		})
+
+		It("does not tag unchanged extractions", func() {
+			tagging := Tagging{Name: "snapshot-{{.Run}}", Owner: "test/primer"}
+			_, err := storeRun(1, "kind: Service\n", tagging)
+			Expect(err).NotTo(HaveOccurred())
+			result, err := storeRun(2, "kind: Service\n", tagging)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Tag).To(BeEmpty())
+			Expect(tags()).To(Equal("snapshot-1\n"))
+		})
+
+		It("adds a suffix to names already taken", func() {
+			tagging := Tagging{Name: "snapshot", Retention: 2, Owner: "test/primer"}
+			for i, tag := range []string{"snapshot", "snapshot-2", "snapshot-3"} {
+				result, err := storeRun(i+1, "kind: Service\nrun: "+strconv.Itoa(i)+"\n", tagging)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Tag).To(Equal(tag))
+			}
+			// Suffixed names count for the retention
+			Expect(tags()).To(Equal("snapshot-2\nsnapshot-3\n"))
+		})
+
+		It("reports tagging failures without failing the pushed run", func() {
+			hook := "#!/bin/sh\nwhile read old new ref; do case $ref in refs/tags/*) echo no tags >&2; exit 1;; esac; done\n"
+			Expect(ioutil.WriteFile(filepath.Join(repo, "hooks", "pre-receive"), []byte(hook), 0755)).To(Succeed())
+			result, err := storeRun(1, "kind: Service\n", Tagging{Name: "snapshot", Owner: "test/primer"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Changed).To(BeTrue())
+			Expect(result.Tag).To(BeEmpty())
+			Expect(result.Error).To(ContainSubstring("tagging failed"))
+			Expect(result.Revision).To(Equal(strings.TrimSpace(run(dir, "--git-dir", repo, "rev-parse", "main"))))
+		})
+	})
+})
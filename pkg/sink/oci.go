@@ -0,0 +1,405 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+const (
+	// ManifestMediaType is the media type of the pushed manifests
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// ConfigMediaType identifies artifacts holding Kubernetes manifests
+	// extracted by GitOps Primer
+	ConfigMediaType = "application/vnd.gitops-primer.manifests.config.v1+json"
+	// LayerMediaType is the media type of the tarball of the manifests
+	LayerMediaType = "application/vnd.gitops-primer.manifests.content.v1.tar+gzip"
+)
+
+// OCI pushes the files as OCI artifact with a single gzipped tarball layer.
+// The artifact is tagged with the time of the run and latest.
+type OCI struct {
+	// Repository such as registry.example.com/team/manifests
+	Repository string
+	Namespace  string
+	// Insecure talks plain HTTP to the registry
+	Insecure bool
+	// DockerConfig is the content of a .dockerconfigjson file holding the
+	// credentials of the registry
+	DockerConfig []byte
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// descriptor references a blob of an OCI artifact
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Store pushes the blobs and tags the manifest
+func (o *OCI) Store(ctx context.Context, tree *export.Tree) (*Result, error) {
+	host, name, err := parseRepository(o.Repository)
+	if err != nil {
+		return nil, err
+	}
+	client, err := o.registryClient(host, name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now
+	if o.Now != nil {
+		now = o.Now
+	}
+	created := now().UTC()
+
+	layer, err := tarball(tree)
+	if err != nil {
+		return nil, err
+	}
+	config, err := json.Marshal(map[string]string{
+		"namespace": o.Namespace,
+		"created":   created.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		Config:        descriptor{MediaType: ConfigMediaType, Digest: digest(config), Size: int64(len(config))},
+		Layers: []descriptor{{
+			MediaType:   LayerMediaType,
+			Digest:      digest(layer),
+			Size:        int64(len(layer)),
+			Annotations: map[string]string{"org.opencontainers.image.title": o.Namespace + ".tar.gz"},
+		}},
+		Annotations: map[string]string{"org.opencontainers.image.created": created.Format(time.RFC3339)},
+	}
+	for _, blob := range [][]byte{config, layer} {
+		if err := client.pushBlob(ctx, blob); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	tag := created.Format(timestampFormat)
+	for _, t := range []string{tag, "latest"} {
+		if err := client.putManifest(ctx, t, data); err != nil {
+			return nil, err
+		}
+	}
+	return &Result{
+		Location: fmt.Sprintf("%s/%s:%s", host, name, tag),
+		Changed:  true,
+		Digest:   digest(data),
+	}, nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// repositoryName matches the path components of repository names
+var repositoryName = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+// RegistryHost returns the host of the registry holding a repository, such
+// as registry-1.docker.io for Docker Hub repositories
+func RegistryHost(repository string) (string, error) {
+	host, _, err := parseRepository(repository)
+	return host, err
+}
+
+// parseRepository splits a repository into registry host and name. Names
+// without a registry refer to Docker Hub.
+func parseRepository(repository string) (string, string, error) {
+	host, name := "registry-1.docker.io", repository
+	if i := strings.IndexByte(repository, '/'); i >= 0 {
+		first := repository[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			host, name = first, repository[i+1:]
+		}
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+	}
+	if host == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if !repositoryName.MatchString(name) {
+		return "", "", fmt.Errorf("invalid repository %q", repository)
+	}
+	return host, name, nil
+}
+
+// dockerConfig is the format of .dockerconfigjson files
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// registryClient returns a client using the credentials of the host
+func (o *OCI) registryClient(host, name string) (*registryClient, error) {
+	client := &registryClient{scheme: "https", host: host, name: name, http: o.HTTPClient}
+	if o.Insecure {
+		client.scheme = "http"
+	}
+	if client.http == nil {
+		client.http = http.DefaultClient
+	}
+	if len(o.DockerConfig) == 0 {
+		return client, nil
+	}
+	config := dockerConfig{}
+	if err := json.Unmarshal(o.DockerConfig, &config); err != nil {
+		return nil, fmt.Errorf("invalid registry credentials: %w", err)
+	}
+	for key, auth := range config.Auths {
+		// Keys are hosts, or URLs such as https://index.docker.io/v1/
+		authHost := key
+		if u, err := url.Parse(key); err == nil && u.Host != "" {
+			authHost = u.Host
+		}
+		if authHost == "docker.io" || authHost == "index.docker.io" {
+			authHost = "registry-1.docker.io"
+		}
+		if authHost != host {
+			continue
+		}
+		client.username, client.password = auth.Username, auth.Password
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid registry credentials for %s: %w", key, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid registry credentials for %s", key)
+			}
+			client.username, client.password = parts[0], parts[1]
+		}
+	}
+	return client, nil
+}
+
+// registryClient talks the OCI distribution API to a repository
+type registryClient struct {
+	scheme   string
+	host     string
+	name     string
+	username string
+	password string
+	// basic or token authenticate requests once the registry asked for it
+	basic bool
+	token string
+	http  *http.Client
+}
+
+// pushBlob uploads a blob unless the registry already has it
+func (c *registryClient) pushBlob(ctx context.Context, blob []byte) error {
+	blobDigest := digest(blob)
+	resp, err := c.do(ctx, http.MethodHead, c.url("blobs/"+blobDigest), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = c.do(ctx, http.MethodPost, c.url("blobs/uploads/"), nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return err
+	}
+	query := location.Query()
+	query.Set("digest", blobDigest)
+	location.RawQuery = query.Encode()
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.do(ctx, http.MethodPut, location.String(), header, blob)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusCreated)
+}
+
+// putManifest tags a manifest
+func (c *registryClient) putManifest(ctx context.Context, tag string, manifest []byte) error {
+	header := http.Header{"Content-Type": {ManifestMediaType}}
+	resp, err := c.do(ctx, http.MethodPut, c.url("manifests/"+tag), header, manifest)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusCreated)
+}
+
+func (c *registryClient) url(path string) string {
+	return fmt.Sprintf("%s://%s/v2/%s/%s", c.scheme, c.host, c.name, path)
+}
+
+// do sends a request, authenticating as challenged by the registry
+func (c *registryClient) do(ctx context.Context, method, u string, header http.Header, body []byte) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.basic {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		return c.http.Do(req)
+	}
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if err := c.authenticate(ctx, challenge); err != nil {
+		return nil, err
+	}
+	return send()
+}
+
+// challengeParam matches the parameters of WWW-Authenticate headers
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate answers a Basic or Bearer challenge. Bearer tokens are
+// requested for pushing to the repository.
+func (c *registryClient) authenticate(ctx context.Context, challenge string) error {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	switch scheme {
+	case "basic":
+		if c.username == "" {
+			return fmt.Errorf("registry %s requires credentials", c.host)
+		}
+		c.basic = true
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("registry %s: unsupported authentication %q", c.host, challenge)
+	}
+
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("registry %s: invalid challenge %q", c.host, challenge)
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull,push", c.name))
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return checkResponse(resp, http.StatusOK)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("invalid token response: %w", err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("registry %s: no token issued", c.host)
+	}
+	return nil
+}
+
+// checkResponse closes the response and fails unless it has the expected
+// status, reporting the errors returned by the registry
+func checkResponse(resp *http.Response, expected int) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == expected {
+		return nil
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	registryErrors := struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if json.Unmarshal(data, &registryErrors) == nil && len(registryErrors.Errors) > 0 {
+		e := registryErrors.Errors[0]
+		return fmt.Errorf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, e.Code, e.Message)
+	}
+	return fmt.Errorf("%s %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// fakeRegistry is an in memory stand-in for the OCI distribution API that
+// issues bearer tokens for the user primer
+type fakeRegistry struct {
+	mu        sync.Mutex
+	url       string
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/token" {
+		if user, password, ok := r.BasicAuth(); !ok || user != "primer" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		Expect(r.URL.Query().Get("scope")).To(Equal("repository:team/manifests:pull,push"))
+		json.NewEncoder(w).Encode(map[string]string{"token": "pushtoken"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer pushtoken" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, f.url))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/team/manifests/")
+	switch {
+	case r.Method == http.MethodHead && strings.HasPrefix(path, "blobs/"):
+		if _, ok := f.blobs[strings.TrimPrefix(path, "blobs/")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPost && path == "blobs/uploads/":
+		f.uploads++
+		w.Header().Set("Location", fmt.Sprintf("/v2/team/manifests/blobs/uploads/%d?state=x", f.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "blobs/uploads/"):
+		Expect(r.URL.Query().Get("state")).To(Equal("x"))
+		data, _ := ioutil.ReadAll(r.Body)
+		if digest(data) != r.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"code":"DIGEST_INVALID","message":"provided digest did not match uploaded content"}]}`))
+			return
+		}
+		f.blobs[digest(data)] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "manifests/"):
+		Expect(r.Header.Get("Content-Type")).To(Equal(ManifestMediaType))
+		data, _ := ioutil.ReadAll(r.Body)
+		f.manifests[strings.TrimPrefix(path, "manifests/")] = data
+		w.Header().Set("Docker-Content-Digest", digest(data))
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("OCI", func() {
+	var (
+		fake   *fakeRegistry
+		server *httptest.Server
+		sink   *OCI
+		tree   *export.Tree
+	)
+
+	BeforeEach(func() {
+		fake = &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+		server = httptest.NewServer(fake)
+		fake.url = server.URL
+		host := strings.TrimPrefix(server.URL, "http://")
+		sink = &OCI{
+			Repository:   host + "/team/manifests",
+			Namespace:    "test",
+			Insecure:     true,
+			DockerConfig: []byte(`{"auths":{"` + host + `":{"auth":"cHJpbWVyOnNlY3JldA=="}}}`),
+			Now:          func() time.Time { return time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC) },
+		}
+		tree = export.NewTree()
+		tree.Add("resources/test", "Service_v1_test_web.yaml", []byte("kind: Service\n"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("pushes an artifact tagged with the run time and latest", func() {
+		result, err := sink.Store(context.Background(), tree)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Location).To(HaveSuffix("/team/manifests:20210601T120000Z"))
+		Expect(fake.manifests).To(HaveKey("20210601T120000Z"))
+		Expect(fake.manifests["latest"]).To(Equal(fake.manifests["20210601T120000Z"]))
+		Expect(result.Digest).To(Equal(digest(fake.manifests["latest"])))
+
+		manifest := ociManifest{}
+		Expect(json.Unmarshal(fake.manifests["latest"], &manifest)).To(Succeed())
+		Expect(manifest.MediaType).To(Equal(ManifestMediaType))
+		Expect(manifest.Config.MediaType).To(Equal(ConfigMediaType))
+		Expect(fake.blobs).To(HaveKey(manifest.Config.Digest))
+		Expect(manifest.Layers).To(HaveLen(1))
+		Expect(manifest.Layers[0].MediaType).To(Equal(LayerMediaType))
+		layer, err := tarball(tree)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.blobs[manifest.Layers[0].Digest]).To(Equal(layer))
+
+		// Blobs the registry already has are not uploaded again
+		uploads := fake.uploads
+		_, err = sink.Store(context.Background(), tree)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.uploads).To(Equal(uploads))
+	})
+
+	It("fails without valid credentials", func() {
+		sink.DockerConfig = nil
+		_, err := sink.Store(context.Background(), tree)
+		Expect(err).To(MatchError(ContainSubstring("401")))
+	})
+
+	It("parses repositories", func() {
+		for repository, expected := range map[string][]string{
+			"registry.example.com/team/manifests": {"registry.example.com", "team/manifests"},
+			"localhost:5000/manifests":            {"localhost:5000", "manifests"},
+			"team/manifests":                      {"registry-1.docker.io", "team/manifests"},
+			"manifests":                           {"registry-1.docker.io", "library/manifests"},
+		} {
+			host, name, err := parseRepository(repository)
+			Expect(err).NotTo(HaveOccurred())
+			Expect([]string{host, name}).To(Equal(expected))
+		}
+		_, _, err := parseRepository("registry.example.com/Team")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,288 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// S3 uploads the files as a gzipped tarball named
+// <prefix>/<namespace>-<time>.tar.gz to an S3 compatible bucket and deletes
+// the tarballs of the namespace beyond the retention count
+type S3 struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	Namespace       string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Retention is the number of tarballs kept, 0 keeps all of them
+	Retention int
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Store uploads the tarball and applies the retention
+func (s *S3) Store(ctx context.Context, tree *export.Tree) (*Result, error) {
+	if s.Endpoint == "" || s.Bucket == "" {
+		return nil, fmt.Errorf("the s3 sink requires an endpoint and a bucket")
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	data, err := tarball(tree)
+	if err != nil {
+		return nil, err
+	}
+	namePrefix := path.Join(s.Prefix, s.Namespace) + "-"
+	key := namePrefix + now().UTC().Format(timestampFormat) + ".tar.gz"
+	header := http.Header{"Content-Type": []string{"application/gzip"}}
+	if _, err := s.do(ctx, http.MethodPut, key, nil, header, data); err != nil {
+		return nil, err
+	}
+	result := &Result{Location: fmt.Sprintf("s3://%s/%s", s.Bucket, key), Changed: true}
+	if s.Retention <= 0 {
+		return result, nil
+	}
+
+	listed, err := s.list(ctx, namePrefix)
+	if err != nil {
+		return nil, err
+	}
+	// The prefix also lists namespaces whose name starts with this one, such
+	// as app-staging for app
+	keys := []string{}
+	for _, listed := range listed {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(listed, namePrefix), ".tar.gz")
+		if _, err := time.Parse(timestampFormat, stamp); err == nil {
+			keys = append(keys, listed)
+		}
+	}
+	sort.Strings(keys)
+	for len(keys) > s.Retention {
+		if _, err := s.do(ctx, http.MethodDelete, keys[0], nil, nil, nil); err != nil {
+			return nil, err
+		}
+		keys = keys[1:]
+	}
+	return result, nil
+}
+
+// listBucketResult is the response of ListObjectsV2
+type listBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// list returns the keys of the tarballs starting with prefix
+func (s *S3) list(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		body, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("invalid list response: %w", err)
+		}
+		for _, object := range result.Contents {
+			if strings.HasSuffix(object.Key, ".tar.gz") {
+				keys = append(keys, object.Key)
+			}
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// s3Error is the error response of S3
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// do sends a signed request for an object of the bucket, or for the bucket
+// itself when key is empty, and returns the response body
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Path = "/" + s.Bucket
+	if key != "" {
+		endpoint.Path += "/" + key
+	}
+	endpoint.RawPath = s3EscapePath(endpoint.Path)
+	endpoint.RawQuery = s3Query(query)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	s.sign(req, body, now())
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		s3Err := s3Error{}
+		if xml.Unmarshal(data, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("s3 %s %s: %s: %s", method, endpoint.Path, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("s3 %s %s: %s", method, endpoint.Path, resp.Status)
+	}
+	return data, nil
+}
+
+// sign adds an AWS Signature Version 4 authorization header covering the
+// host, the payload and all headers set on the request
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	payloadHash := sha256.Sum256(body)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := strings.Join([]string{amzDate[:8], region, "s3", "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape encodes everything but the unreserved characters as S3 expects
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i := range segments {
+		segments[i] = s3Escape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3Query encodes a query in the canonical order and encoding
+func s3Query(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := []string{}
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sink stores the files of an extraction. The git sink commits them
+// to a repository, other sinks archive them.
+package sink
+
+import (
+	"context"
+
+	"github.com/cooktheryan/gitops-primer/pkg/export"
+)
+
+// timestampFormat names archives and tags after the time of the run so
+// that they sort by time
+const timestampFormat = "20060102T150405Z"
+
+// Sink stores the files of an extraction
+type Sink interface {
+	// Store writes the tree to the sink
+	Store(ctx context.Context, tree *export.Tree) (*Result, error)
+}
+
+// Result describes what a sink stored. The extraction reports it to the
+// controller as the termination message of its pod.
+type Result struct {
+	// Location is where the files were stored
+	Location string `json:"location"`
+	// Changed is false when the sink already held the same files
+	Changed bool `json:"changed"`
+	// Digest of the pushed OCI artifact
+	Digest string `json:"digest,omitempty"`
+	// Revision is the commit of the git sink holding the files
+	Revision string `json:"revision,omitempty"`
+	// Objects is the number of stored objects
+	Objects int `json:"objects,omitempty"`
+	// CreatedBranch is set when the git sink created the branch
+	CreatedBranch bool `json:"createdBranch,omitempty"`
+	// Tag is the tag created by the git sink
+	Tag string `json:"tag,omitempty"`
+	// PushRetries counts the pushes of the git sink retried on top of a
+	// branch that moved
+	PushRetries int `json:"pushRetries,omitempty"`
+	// Error tells why storing failed
+	Error string `json:"error,omitempty"`
+}
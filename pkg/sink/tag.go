@@ -0,0 +1,186 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Tagging configures the annotated tags the git sink creates after pushing
+type Tagging struct {
+	// Name is a text/template of the tag name, see TagVars
+	Name string
+	// Retention is the number of tags of the Owner matching Name kept, 0
+	// keeps all of them
+	Retention int
+	// Owner identifies the extraction in the messages of its tags, only its
+	// own tags are deleted
+	Owner string
+	// Vars are the values of the template, Date and Timestamp are set to
+	// the time of the tag
+	Vars TagVars
+}
+
+// TagVars are the values available to the tag name template
+type TagVars struct {
+	Date      string
+	Timestamp string
+	Run       int
+	Namespace string
+	Name      string
+}
+
+// tag creates an annotated tag of the pushed commit and pushes it, then
+// deletes the oldest tags of the owner matching the name template beyond the
+// retention.
+// A name already taken gets the first free suffix -2, -3 and so on.
+func (g *Git) tag(ctx context.Context, result *Result) error {
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+	created := now().UTC()
+	vars := g.Tagging.Vars
+	vars.Date = created.Format("2006-01-02")
+	vars.Timestamp = created.Format(timestampFormat)
+	tmpl, err := template.New("tag").Option("missingkey=error").Parse(g.Tagging.Name)
+	if err != nil {
+		return fmt.Errorf("invalid tag name %q: %w", g.Tagging.Name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return fmt.Errorf("invalid tag name %q: %w", g.Tagging.Name, err)
+	}
+	name := buf.String()
+	if _, err := g.git(ctx, g.Dir, "check-ref-format", "refs/tags/"+name); err != nil {
+		return fmt.Errorf("invalid tag name %q", name)
+	}
+
+	// Other extractions may have pushed tags since the clone
+	if _, err := g.git(ctx, g.Dir, "fetch", "-q", "--force", "--tags", "origin"); err != nil {
+		return err
+	}
+	base := name
+	for i := 2; ; i++ {
+		if _, err := g.git(ctx, g.Dir, "rev-parse", "-q", "--verify", "refs/tags/"+name); err != nil {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	ref := "refs/tags/" + name
+
+	// The tagger date orders the tags for the retention
+	env := []string{"GIT_COMMITTER_DATE=" + created.Format(time.RFC3339)}
+	if _, err := g.gitEnv(ctx, g.Dir, env, "tag", "-a", "-m", tagSubject(g.Tagging.Owner), name); err != nil {
+		return err
+	}
+	if _, err := g.git(ctx, g.Dir, "push", "-q", "origin", ref); err != nil {
+		return err
+	}
+	result.Tag = name
+	if g.Tagging.Retention <= 0 {
+		return nil
+	}
+
+	pattern, err := tagPattern(tmpl, vars)
+	if err != nil {
+		return err
+	}
+	// Only annotated tags of the owner count, tags of other Extracts sharing
+	// the template carry another subject
+	out, err := g.git(ctx, g.Dir, "for-each-ref", "--sort=-creatordate", "--format=%(refname:strip=2) %(objecttype) %(contents:subject)", "refs/tags")
+	if err != nil {
+		return err
+	}
+	subject := tagSubject(g.Tagging.Owner)
+	var stale []string
+	kept := 0
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 3 || fields[1] != "tag" || fields[2] != subject || !pattern.MatchString(fields[0]) {
+			continue
+		}
+		tag := fields[0]
+		if kept < g.Tagging.Retention {
+			kept++
+			continue
+		}
+		stale = append(stale, ":refs/tags/"+tag)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	_, err = g.git(ctx, g.Dir, append([]string{"push", "-q", "origin"}, stale...)...)
+	return err
+}
+
+// tagSubject returns the message of the tags of owner
+func tagSubject(owner string) string {
+	return "Snapshot of " + owner
+}
+
+// tagPattern returns the regular expression matching the names the template
+// yields for the Namespace and Name of vars, with any suffix added to
+// de-duplicate them. Other variables match any value of their format.
+func tagPattern(tmpl *template.Template, vars TagVars) (*regexp.Regexp, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+			pattern.WriteString(regexp.QuoteMeta(string(node.Text)))
+		case *parse.ActionNode:
+			pattern.WriteString(actionPattern(node, vars))
+		default:
+			pattern.WriteString(".*")
+		}
+	}
+	pattern.WriteString(`(-\d+)?$`)
+	return regexp.Compile(pattern.String())
+}
+
+// actionPattern returns the regular expression matching the values of a
+// template action printing a variable, and any value for other actions
+func actionPattern(node *parse.ActionNode, vars TagVars) string {
+	if len(node.Pipe.Decl) > 0 || len(node.Pipe.Cmds) != 1 || len(node.Pipe.Cmds[0].Args) != 1 {
+		return ".*"
+	}
+	field, ok := node.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return ".*"
+	}
+	switch field.Ident[0] {
+	case "Date":
+		return `\d{4}-\d{2}-\d{2}`
+	case "Timestamp":
+		return `\d{8}T\d{6}Z`
+	case "Run":
+		return `\d+`
+	case "Namespace":
+		return regexp.QuoteMeta(vars.Namespace)
+	case "Name":
+		return regexp.QuoteMeta(vars.Name)
+	}
+	return ".*"
+}